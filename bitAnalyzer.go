@@ -1,37 +1,45 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"unsafe"
 
+	"github.com/RizzoYN/RegisterAnalyzer/regmodel"
 	"github.com/ying32/govcl/vcl"
 	"github.com/ying32/govcl/vcl/types"
 )
 
 const (
-	padx      = 4
-	pady      = 4
-	bitBgX    = 20
-	bitBgY    = 25
-	bitWidth  = 32
-	bitNumEdX = int32(2.5*bitWidth) + 10
-	ButtonS   = 30
-	winX      = (bitWidth+1)*bitBgX + 2*padx + bitNumEdX + 5*ButtonS
+	padx    = 4
+	pady    = 4
+	bitBgX  = 20
+	bitBgY  = 25
+	ButtonS = 30
+	nameW   = 60
+	// maxBitWidth bounds the width typed into the width box
+	maxBitWidth = 256
 )
 
 var (
-	color = map[string]types.TColor{
+	bitWidth  = 32
+	bitNumEdX = int32(bitWidth*5/2) + 10
+	winX      = int32(bitWidth+2)*bitBgX + 2*padx + bitNumEdX + 5*ButtonS + nameW
+	color     = map[string]types.TColor{
 		"0":    types.TColor(0xffffff),
 		"1":    types.TColor(0xffff88),
 		"diff": types.TColor(0xffaaff),
 		"same": types.TColor(0xf0f0f0),
 	}
-	Row    = 1
-	MaxRow = 3
-	winY   = int32(bitBgY*(Row+1)+pady*2) + 50
+	Row         = 1
+	visibleRows = 8
+	winY        = int32(bitBgY*(Row+1)+pady*2) + 50
+	rowSuffix   = regexp.MustCompile(`\d+$`)
+	// widthPresets are listed in the width box, any other width can be typed
+	widthPresets = []int{8, 16, 24, 32, 64, 128}
 )
 
 type bit interface {
@@ -89,8 +97,8 @@ func newMemo(parent vcl.IWinControl, x, y, w, h int32, ix, row, bitWidth int, co
 	return memo
 }
 
-func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, color types.TColor, show bool, fnc vcl.TKeyEvent, fn ...vcl.TNotifyEvent) BitLoc {
-	bit := make(BitLoc, bitWidth+7)
+func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, color types.TColor, show bool, fnc vcl.TKeyEvent, drag, drop vcl.TMouseEvent, fn ...vcl.TNotifyEvent) BitLoc {
+	bit := make(BitLoc, bitWidth+9)
 	for c := 0; c < bitWidth; c++ {
 		bit[c] = newMemo(parent, x, y, w, h, c, row, bitWidth, color, "0", show, fn[0])
 	}
@@ -110,7 +118,7 @@ func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, colo
 	shiftnum.SetParent(parent)
 	shiftnum.SetBounds(int32(padx+bitWidth*bitBgX)+bitNumEdX+ButtonS, padx+int32(row)*bitBgY+50, bitBgX, bitBgY)
 	shiftnum.SetTextBuf("1")
-	shiftnum.SetMaxLength(2)
+	shiftnum.SetMaxLength(3)
 	shiftnum.SetAlignment(types.TaCenter)
 	rshift := vcl.NewButton(parent)
 	rshift.SetParent(parent)
@@ -136,6 +144,20 @@ func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, colo
 	cler.SetTextBuf("清空")
 	cler.SetOnClick(fn[4])
 	cler.SetName(fmt.Sprintf("cler%d", row-1))
+	grip := vcl.NewPanel(parent)
+	grip.SetParent(parent)
+	grip.SetBounds(int32(padx+(bitWidth+1)*bitBgX)+bitNumEdX+ButtonS*5, padx+int32(row)*bitBgY+50, bitBgX, bitBgY)
+	grip.SetCaption("≡")
+	grip.SetColor(types.TColor(0xf0f0f0))
+	grip.SetCursor(types.CrSizeNS)
+	grip.SetOnMouseDown(drag)
+	grip.SetOnMouseUp(drop)
+	grip.SetName(fmt.Sprintf("grip%d", row-1))
+	name := vcl.NewEdit(parent)
+	name.SetParent(parent)
+	name.SetBounds(int32(padx+(bitWidth+2)*bitBgX)+bitNumEdX+ButtonS*5, padx+int32(row)*bitBgY+50, nameW, bitBgY)
+	name.SetName(fmt.Sprintf("name%d", row-1))
+	name.SetTextBuf("")
 	if show {
 		numEdit.Show()
 		lshift.Show()
@@ -144,6 +166,8 @@ func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, colo
 		rev.Show()
 		invt.Show()
 		cler.Show()
+		grip.Show()
+		name.Show()
 	} else {
 		numEdit.Hide()
 		lshift.Hide()
@@ -152,6 +176,8 @@ func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, colo
 		rev.Hide()
 		invt.Hide()
 		cler.Hide()
+		grip.Hide()
+		name.Hide()
 	}
 	bit[bitWidth] = numEdit
 	bit[bitWidth+1] = lshift
@@ -160,23 +186,45 @@ func newBitLoc(parent vcl.IWinControl, x, y, w, h int32, bitWidth, row int, colo
 	bit[bitWidth+4] = rev
 	bit[bitWidth+5] = invt
 	bit[bitWidth+6] = cler
+	bit[bitWidth+7] = name
+	bit[bitWidth+8] = grip
 	return bit
 }
 
 type TMainForm struct {
 	*vcl.TForm
 	BitLocs    []BitLoc
+	Regs       []*regmodel.Register
+	Compare    *regmodel.Comparison
 	BitHeader  []*vcl.TMemo
 	BaseChoise *vcl.TRadioGroup
 	base       int
 	AddRow     *vcl.TButton
 	RmRow      *vcl.TButton
 	OnTop      *vcl.TCheckBox
+	WidthBox   *vcl.TComboBox
 }
 
 var mainForm *TMainForm
 
+func SetBitWidth(width int) {
+	bitWidth = width
+	bitNumEdX = int32(bitWidth*5/2) + 10
+	if bitNumEdX < 4*bitBgX {
+		bitNumEdX = 4 * bitBgX
+	}
+	winX = int32(bitWidth+2)*bitBgX + 2*padx + bitNumEdX + 5*ButtonS + nameW
+	if winX < 16*ButtonS {
+		winX = 16 * ButtonS
+	}
+}
+
 func main() {
+	width := flag.Int("width", bitWidth, "寄存器位宽")
+	flag.Parse()
+	if *width > 0 && *width <= maxBitWidth {
+		SetBitWidth(*width)
+	}
 	vcl.Application.Initialize()
 	vcl.Application.SetMainFormOnTaskBar(true)
 	vcl.Application.CreateForm(&mainForm)
@@ -188,27 +236,34 @@ func main() {
 func (f *TMainForm) OnFormCreate(sender vcl.IObject) {
 	f.SetCaption("寄存器工具")
 	f.SetClientHeight(winY)
-	f.SetClientWidth(winX)
+	f.SetAutoScroll(true)
 	f.initComponents(f, bitWidth, Row, color)
 }
 
+// SetRowHeight fits the form to the rows, scrolling past visibleRows.
+func (f *TMainForm) SetRowHeight() {
+	rows := Row
+	if rows > visibleRows {
+		rows = visibleRows
+	}
+	winY = int32(bitBgY*(rows+1)+pady*2) + 50
+	f.SetClientHeight(winY)
+}
+
 func (f *TMainForm) initComponents(parent vcl.IWinControl, cols, rows int, color map[string]types.TColor) {
 	f.base = 16
 	addrow := vcl.NewButton(parent)
 	addrow.SetParent(parent)
-	addrow.SetBounds(winX-padx-ButtonS*2, pady+5, ButtonS*2, 20)
 	addrow.SetTextBuf("增加一行")
 	addrow.SetOnClick(f.AddR)
 	rmrow := vcl.NewButton(parent)
 	rmrow.SetParent(parent)
-	rmrow.SetBounds(winX-padx-ButtonS*2, pady+25, ButtonS*2, 20)
 	rmrow.SetEnabled(false)
 	rmrow.SetTextBuf("删除一行")
 	rmrow.SetOnClick(f.RemoveR)
 	checkgroup := vcl.NewRadioGroup(parent)
 	checkgroup.SetParent(parent)
 	checkgroup.SetCaption("进制")
-	checkgroup.SetBounds(winX-padx*2-120-ButtonS*2, pady, 120, 46)
 	checkgroup.SetColumns(3)
 	checkbutton16 := vcl.NewRadioButton(checkgroup)
 	checkbutton16.SetParent(checkgroup)
@@ -226,211 +281,268 @@ func (f *TMainForm) initComponents(parent vcl.IWinControl, cols, rows int, color
 	cb := vcl.NewCheckBox(parent)
 	cb.SetParent(parent)
 	cb.SetCaption("置顶")
-	cb.SetBounds(winX-padx*5-160-ButtonS*2, 20, 10, 10)
 	cb.SetOnClick(f.ClickOnTop)
 	f.OnTop = cb
-	headers := make([]*vcl.TMemo, cols)
-	bits := make([]BitLoc, MaxRow)
-	for r := 0; r <= MaxRow; r++ {
-		show := r <= 1
-		if r == 0 {
-			for col := 0; col < cols; col++ {
-				headers[col] = newMemo(parent, padx, pady+50, bitBgX, bitBgY, col, r, bitWidth, color["same"], fmt.Sprint(bitWidth-col-1), show)
-			}
-		} else {
-			bits[r-1] = newBitLoc(parent, padx, pady+50, bitBgX, bitBgY, bitWidth, r, color["0"], show, f.Typed, f.Clicked, f.ClickShift, f.ClickReverse, f.ClickInvert, f.ClickClear)
-		}
+	widthLabel := vcl.NewLabel(parent)
+	widthLabel.SetParent(parent)
+	widthLabel.SetCaption("位宽")
+	widthLabel.SetBounds(padx, pady+20, 30, 20)
+	widthBox := vcl.NewComboBox(parent)
+	widthBox.SetParent(parent)
+	widthBox.SetBounds(padx+30, pady+16, 56, 25)
+	for _, width := range widthPresets {
+		widthBox.Items().Add(fmt.Sprint(width))
+	}
+	widthBox.SetTextBuf(fmt.Sprint(cols))
+	widthBox.SetOnSelect(f.WidthSelect)
+	widthBox.SetOnEditingDone(f.WidthTyped)
+	f.WidthBox = widthBox
+	f.NewHeaders(color)
+	f.Compare = regmodel.NewComparison()
+	for r := 1; r <= rows; r++ {
+		f.NewRow(r, regmodel.NewRegister(cols))
 	}
-	f.BitLocs = bits
 	f.BaseChoise = checkgroup
-	f.BitHeader = headers
 	f.AddRow = addrow
 	f.RmRow = rmrow
+	f.PlaceControls()
+}
+
+// PlaceControls fits the form and the controls right of the rows to winX.
+func (f *TMainForm) PlaceControls() {
+	f.SetClientWidth(winX)
+	f.AddRow.SetBounds(winX-padx-ButtonS*2, pady+5, ButtonS*2, 20)
+	f.RmRow.SetBounds(winX-padx-ButtonS*2, pady+25, ButtonS*2, 20)
+	f.BaseChoise.SetBounds(winX-padx*2-120-ButtonS*2, pady, 120, 46)
+	f.OnTop.SetBounds(winX-padx*5-160-ButtonS*2, 20, 10, 10)
+}
+
+// NewHeaders creates the bit number above each column.
+func (f *TMainForm) NewHeaders(color map[string]types.TColor) {
+	f.BitHeader = make([]*vcl.TMemo, bitWidth)
+	for col := 0; col < bitWidth; col++ {
+		f.BitHeader[col] = newMemo(f, padx, pady+50, bitBgX, bitBgY, col, 0, bitWidth, color["same"], fmt.Sprint(bitWidth-col-1), true)
+	}
+}
+
+// NewRow creates the controls of row r, counted from 1, showing reg.
+func (f *TMainForm) NewRow(r int, reg *regmodel.Register) {
+	bits := newBitLoc(f, padx, pady+50, bitBgX, bitBgY, bitWidth, r, color["0"], true, f.Typed, f.DragRow, f.DropRow, f.Clicked, f.ClickShift, f.ClickReverse, f.ClickInvert, f.ClickClear)
+	f.BitLocs = append(f.BitLocs, bits)
+	f.Regs = append(f.Regs, reg)
+	f.Compare.Add(reg)
 }
 
 func (f *TMainForm) Typed(sender vcl.IObject, key *types.Char, shift types.TShiftState) {
 	var str string
 	num := vcl.AsMemo(sender)
-	num.GetTextBuf(&str, bitWidth)
+	num.GetTextBuf(&str, int32(bitWidth))
 	rowIx := f.GetRowIndex(num)
-	resNum, err := strconv.ParseInt(str, f.base, bitWidth*2)
-	if err != nil && str != "" {
-		bitList := f.GetBitString(int(rowIx))
-		binStr := strings.Join(bitList, "")
-		bin, _ := strconv.ParseInt(binStr, 2, bitWidth*2)
-		f.UpdateBitNum(bin, rowIx)
+	if err := f.Regs[rowIx].SetString(str, f.base); err != nil {
+		f.UpdateBitNum(rowIx)
 		num.SetSelStart(int32(len(str)))
-		var bitString string
-		f.BitLocs[rowIx][bitWidth].GetTextBuf(&bitString, bitWidth*2)
-		resNum, _ = strconv.ParseInt(bitString, f.base, bitWidth*2)
 	}
-	resNum &= 0xffffffff
-	f.UpdateBit(rowIx, resNum)
+	f.UpdateBit(rowIx)
 }
 
 func (f *TMainForm) Clicked(sender vcl.IObject) {
-	var str string
-	bitMap := make(map[string]int, Row)
 	bit := vcl.AsMemo(sender)
-	bit.GetTextBuf(&str, 2)
 	bit.SetMaxLength(2)
-	if str == "1" {
-		bit.SetTextBuf("0")
-		bit.SetColor(color["0"])
-	} else {
-		bit.SetTextBuf("1")
-		bit.SetColor(color["1"])
-	}
 	bit.SetAlignment(types.TaCenter)
 	rowIx := f.GetRowIndex(bit)
 	colIx := f.GetColIndex(bit)
-	for i := 0; i < Row; i++ {
-		var bitString string
-		f.BitLocs[i][colIx].GetTextBuf(&bitString, 2)
-		bitMap[bitString] = 0
-	}
-	f.UpdateHeader(bitMap, int(colIx))
-	bitList := f.GetBitString(int(rowIx))
-	binStr := strings.Join(bitList, "")
-	bin, _ := strconv.ParseInt(binStr, 2, bitWidth*2)
-	f.UpdateBitNum(bin, int64(rowIx))
+	f.Regs[rowIx].Toggle(bitWidth - 1 - int(colIx))
+	f.UpdateBit(rowIx)
+	f.UpdateBitNum(rowIx)
 }
 
 func (f *TMainForm) BaseChange(sender vcl.IObject) {
 	var str string
-	oldbase := f.base
 	ra := vcl.AsRadioButton(sender)
 	ra.GetTextBuf(&str, 4)
 	base, _ := strconv.ParseInt(str, 10, 16)
 	f.base = int(base)
 	for i := 0; i < Row; i++ {
-		var bitString string
-		f.BitLocs[i][bitWidth].GetTextBuf(&bitString, bitWidth)
-		num, _ := strconv.ParseInt(bitString, oldbase, bitWidth*2)
-		f.UpdateBitNum(num, int64(i))
+		f.UpdateBitNum(int64(i))
 	}
 }
 
 func (f *TMainForm) ClickClear(sender vcl.IObject) {
 	cler := vcl.AsButton(sender)
 	rowIx := f.GetRowIndex(cler)
-	f.BitLocs[rowIx][bitWidth].SetTextBuf("0")
-	for c := 0; c < bitWidth; c++ {
-		bitMap := make(map[string]int, Row)
-		for i := 0; i < MaxRow; i++ {
-			var bitString string
-			if int64(i) == rowIx {
-				f.BitLocs[rowIx][c].SetTextBuf("0")
-				f.BitLocs[rowIx][c].SetColor(color["0"])
-			}
-			f.BitLocs[i][c].GetTextBuf(&bitString, 2)
-			bitMap[bitString] = 0
-		}
-		f.UpdateHeader(bitMap, c)
-	}
+	f.Regs[rowIx].Clear()
+	f.UpdateBitNum(rowIx)
+	f.UpdateBit(rowIx)
 }
 
 func (f *TMainForm) ClickInvert(sender vcl.IObject) {
 	inv := vcl.AsButton(sender)
 	rowIx := f.GetRowIndex(inv)
-	for i := 0; i < bitWidth; i++ {
-		f.Clicked(f.BitLocs[rowIx][i])
-	}
+	f.Regs[rowIx].Invert()
+	f.UpdateBitNum(rowIx)
+	f.UpdateBit(rowIx)
 }
 
 func (f *TMainForm) ClickShift(sender vcl.IObject) {
 	shift := vcl.AsButton(sender)
 	cname := shift.Name()
 	rowIx := f.GetRowIndex(shift)
-	var col int
-	if cname[0] == 'l' {
-		col = 0
-	} else {
-		col = 1
-	}
 	var str string
 	f.BitLocs[rowIx][bitWidth+2].GetTextBuf(&str, 8)
-	shiftNum, _ := strconv.ParseInt(str, 10, 16)
-	f.BitLocs[rowIx][bitWidth].GetTextBuf(&str, bitWidth*32)
-	num, _ := strconv.ParseInt(str, f.base, bitWidth*2)
-	switch col {
-	case 0:
-		num <<= shiftNum
-	case 1:
-		num >>= shiftNum
+	shiftNum, _ := strconv.ParseUint(str, 10, 16)
+	if cname[0] == 'l' {
+		f.Regs[rowIx].Lsh(uint(shiftNum))
+	} else {
+		f.Regs[rowIx].Rsh(uint(shiftNum))
 	}
-	num &= 0xffffffff
-	f.UpdateBitNum(num, rowIx)
-	f.UpdateBit(rowIx, num)
+	f.UpdateBitNum(rowIx)
+	f.UpdateBit(rowIx)
 }
 
 func (f *TMainForm) ClickReverse(sender vcl.IObject) {
 	rev := vcl.AsButton(sender)
 	rowIx := f.GetRowIndex(rev)
-	bins := make([]string, bitWidth)
-	for c := 0; c < bitWidth; c++ {
-		bitMap := make(map[string]int, Row)
-		for i := 0; i < Row; i++ {
-			var str string
-			if int64(i) == rowIx {
-				f.BitLocs[i][c].GetTextBuf(&str, 2)
-				bins[bitWidth-c-1] = str
-			} else {
-				f.BitLocs[i][bitWidth-c-1].GetTextBuf(&str, 2)
-			}
-			bitMap[str] = 0
-		}
-		f.UpdateHeader(bitMap, bitWidth-c-1)
-	}
-	binStr := strings.Join(bins, "")
-	bin, _ := strconv.ParseInt(binStr, 2, bitWidth*2)
-	f.UpdateBitNum(bin, rowIx)
-	for i, bin := range bins {
-		f.BitLocs[rowIx][i].SetTextBuf(bin)
-		f.BitLocs[rowIx][i].SetColor(color[bin])
-	}
+	f.Regs[rowIx].Reverse()
+	f.UpdateBitNum(rowIx)
+	f.UpdateBit(rowIx)
 }
 
 func (f *TMainForm) AddR(sender vcl.IObject) {
 	Row++
 	f.RmRow.SetEnabled(true)
-	if Row == MaxRow {
-		f.AddRow.SetEnabled(false)
-	}
-	winY = int32(bitBgY*(Row+1)+pady*2) + 50
-	for _, obj := range f.BitLocs[Row-1] {
-		tmp := obj
-		go func(j bit) {
-			vcl.ThreadSync(func() {
-				j.Show()
-			})
-		}(tmp)
-	}
-	f.SetHeight(winY)
+	f.NewRow(Row, regmodel.NewRegister(bitWidth))
+	f.SetRowHeight()
 	f.Repaint()
 	f.UpdateHeaders()
 }
 
 func (f *TMainForm) RemoveR(sender vcl.IObject) {
 	Row--
-	f.AddRow.SetEnabled(true)
 	if Row == 1 {
 		f.RmRow.SetEnabled(false)
 	}
-	bits := f.BitLocs[Row]
-	winY = int32(bitBgY*(Row+1)+pady*2) + 50
-	f.SetHeight(winY)
-	f.ClickClear(bits[bitWidth+6])
+	for _, obj := range f.BitLocs[Row] {
+		obj.Free()
+	}
+	f.BitLocs = f.BitLocs[:Row]
+	f.Regs = f.Regs[:Row]
+	f.Compare.Remove(Row)
+	f.SetRowHeight()
 	f.UpdateHeaders()
-	for _, obj := range bits {
-		obj.Hide()
+}
+
+// WidthSelect applies the preset picked in the width box.
+func (f *TMainForm) WidthSelect(sender vcl.IObject) {
+	if i := f.WidthBox.ItemIndex(); i >= 0 {
+		f.SetWidth(widthPresets[i])
 	}
 }
 
+// WidthTyped applies the width typed into the width box.
+func (f *TMainForm) WidthTyped(sender vcl.IObject) {
+	var str string
+	f.WidthBox.GetTextBuf(&str, 8)
+	width, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		width = 0
+	}
+	f.SetWidth(width)
+}
+
+// SetWidth re-lays out the headers and rows for width and masks every row
+// to it. Widths out of range restore the box.
+func (f *TMainForm) SetWidth(width int) {
+	if width < 1 || width > maxBitWidth || width == bitWidth {
+		f.WidthBox.SetTextBuf(fmt.Sprint(bitWidth))
+		return
+	}
+	names := make([]string, Row)
+	for r := range names {
+		names[r] = f.RowName(r)
+	}
+	for _, header := range f.BitHeader {
+		header.Free()
+	}
+	for _, bits := range f.BitLocs {
+		for _, obj := range bits {
+			obj.Free()
+		}
+	}
+	SetBitWidth(width)
+	regs := f.Regs
+	f.BitLocs, f.Regs = nil, nil
+	f.Compare = regmodel.NewComparison()
+	f.NewHeaders(color)
+	for r, reg := range regs {
+		reg.SetWidth(width)
+		f.NewRow(r+1, reg)
+		f.BitLocs[r][bitWidth+7].SetTextBuf(names[r])
+		f.UpdateBitNum(int64(r))
+		f.UpdateBit(int64(r))
+	}
+	f.PlaceControls()
+	f.WidthBox.SetTextBuf(fmt.Sprint(width))
+}
+
+// RowName is the name typed at the end of row r.
+func (f *TMainForm) RowName(r int) string {
+	var str string
+	f.BitLocs[r][bitWidth+7].GetTextBuf(&str, 256)
+	return str
+}
+
+// DragRow marks the grip of the row about to move.
+func (f *TMainForm) DragRow(sender vcl.IObject, button types.TMouseButton, shift types.TShiftState, x, y int32) {
+	vcl.AsPanel(sender).SetColor(color["1"])
+}
+
+// DropRow moves the row to the row its grip is released over; y is relative
+// to the grip, which is one row high.
+func (f *TMainForm) DropRow(sender vcl.IObject, button types.TMouseButton, shift types.TShiftState, x, y int32) {
+	grip := vcl.AsPanel(sender)
+	grip.SetColor(color["same"])
+	r := int(f.GetRowIndex(grip))
+	to := r + int(y)/bitBgY
+	if y < 0 {
+		to = r + (int(y)-bitBgY+1)/bitBgY
+	}
+	if to < 0 {
+		to = 0
+	} else if to >= Row {
+		to = Row - 1
+	}
+	if to != r {
+		f.MoveRow(r, to)
+	}
+}
+
+// MoveRow moves row from to position to, shifting the rows between. The
+// controls stay in place, the registers and names move through them.
+func (f *TMainForm) MoveRow(from, to int) {
+	order := make([]int, 0, Row)
+	for r := 0; r < Row; r++ {
+		if r != from {
+			order = append(order, r)
+		}
+	}
+	order = append(order[:to], append([]int{from}, order[to:]...)...)
+	regs := make([]*regmodel.Register, Row)
+	names := make([]string, Row)
+	for r, ix := range order {
+		regs[r], names[r] = f.Regs[ix], f.RowName(ix)
+	}
+	f.Regs = regs
+	f.Compare = regmodel.NewComparison(regs...)
+	for r := range regs {
+		f.BitLocs[r][bitWidth+7].SetTextBuf(names[r])
+		f.UpdateBitNum(int64(r))
+		f.UpdateBit(int64(r))
+	}
+}
+
+// GetRowIndex reads the row from the digits that end the control name.
 func (f *TMainForm) GetRowIndex(sender vcl.IWinControl) int64 {
-	cname := sender.Name()
-	name := string(cname[len(cname)-1])
-	rowIx, _ := strconv.ParseInt(name, 10, 0)
+	rowIx, _ := strconv.ParseInt(rowSuffix.FindString(sender.Name()), 10, 0)
 	return rowIx
 }
 
@@ -442,73 +554,29 @@ func (f *TMainForm) GetColIndex(sender vcl.IWinControl) int64 {
 	return colIx
 }
 
-func (f *TMainForm) UpdateBitNum(bin, r int64) {
-	switch f.base {
-	case 16:
-		f.BitLocs[r][bitWidth].SetTextBuf(fmt.Sprintf("%x", bin))
-	case 10:
-		f.BitLocs[r][bitWidth].SetTextBuf(fmt.Sprint(bin))
-	case 8:
-		f.BitLocs[r][bitWidth].SetTextBuf(fmt.Sprintf("%o", bin))
-	}
-}
-
-func (f *TMainForm) UpdateBit(row, resNum int64) {
-	binStr := strconv.FormatInt(resNum, 2)
-	n := len(binStr)
-	sum := 0
-	for c := bitWidth - 1; c >= 0; c-- {
-		bitMap := make(map[string]int, Row)
-		for r := 0; r < Row; r++ {
-			var binString string
-			f.BitLocs[r][c].GetTextBuf(&binString, 2)
-			if int64(r) == row {
-				if sum < n {
-					s := string(binStr[n-sum-1])
-					f.BitLocs[r][c].SetTextBuf(s)
-					f.BitLocs[r][c].SetColor(color[s])
-					binString = s
-				} else {
-					f.BitLocs[r][c].SetTextBuf("0")
-					f.BitLocs[r][c].SetColor(color["0"])
-					binString = "0"
-				}
-			}
-			bitMap[binString] = 0
-		}
-		f.UpdateHeader(bitMap, c)
-		sum++
-	}
+func (f *TMainForm) UpdateBitNum(r int64) {
+	f.BitLocs[r][bitWidth].SetTextBuf(f.Regs[r].Text(f.base))
 }
 
-func (f *TMainForm) UpdateHeader(bitMap map[string]int, c int) {
-	if len(bitMap) == 1 {
-		f.BitHeader[c].SetColor(color["same"])
-	} else {
-		f.BitHeader[c].SetColor(color["diff"])
+func (f *TMainForm) UpdateBit(row int64) {
+	bins := f.Regs[row].Bits()
+	for c := 0; c < bitWidth; c++ {
+		s := string(bins[c])
+		f.BitLocs[row][c].SetTextBuf(s)
+		f.BitLocs[row][c].SetColor(color[s])
 	}
+	f.UpdateHeaders()
 }
 
 func (f *TMainForm) UpdateHeaders() {
+	mask := f.Compare.DiffMask()
 	for c := 0; c < bitWidth; c++ {
-		bitMap := make(map[string]int, Row)
-		for r := 0; r < Row; r++ {
-			var str string
-			f.BitLocs[r][c].GetTextBuf(&str, 2)
-			bitMap[str] = 0
+		if mask.Bit(bitWidth-1-c) == 0 {
+			f.BitHeader[c].SetColor(color["same"])
+		} else {
+			f.BitHeader[c].SetColor(color["diff"])
 		}
-		f.UpdateHeader(bitMap, c)
-	}
-}
-
-func (f *TMainForm) GetBitString(r int) []string {
-	bitList := make([]string, bitWidth)
-	for i := 0; i < bitWidth; i++ {
-		var bitString string
-		f.BitLocs[r][i].GetTextBuf(&bitString, 2)
-		bitList[i] = bitString
 	}
-	return bitList
 }
 
 func (f *TMainForm) ClickOnTop(sender vcl.IObject) {
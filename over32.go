@@ -2,11 +2,15 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/big"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 
+	"github.com/RizzoYN/RegisterAnalyzer/regmodel"
 	"github.com/pwiecz/go-fltk"
 )
 
@@ -23,27 +27,49 @@ var (
 		11: fltk.HELVETICA,
 		14: fltk.HELVETICA_BOLD,
 	}
-	textMap = map[string]string{
-		"0": "1",
-		"1": "0",
-	}
 	MLmap = map[string]string{
 		"MSB": "LSB",
 		"LSB": "MSB",
 	}
+	fieldColorMap = map[int]fltk.Color{
+		0: fltk.Color(0xE0ECFF00),
+		1: fltk.Color(0xFFF0D800),
+	}
+	floatColorMap = map[int]fltk.Color{
+		0: fltk.Color(0xFFC8C800),
+		1: fltk.Color(0xC8F0C800),
+		2: fltk.Color(0xC8DCFF00),
+	}
+	tagColors = []fltk.Color{
+		0, fltk.Color(0xFFB4B400), fltk.Color(0xFFD8A000), fltk.Color(0xFFF08C00),
+		fltk.Color(0xB4E6B400), fltk.Color(0xB4E6E600), fltk.Color(0xB4C8FF00), fltk.Color(0xDCB4F000),
+	}
+	floatParts              = []string{"符号", "指数", "尾数"}
+	tagNames                = []string{"无", "红", "橙", "黄", "绿", "青", "蓝", "紫"}
+	changedBitColor         = fltk.YELLOW
+	errorColor              = fltk.Color(0xFFC8C800)
+	keyEnter                = 0xff0d
+	keyKPEnter              = 0xff8d
 	pad                     = 2
 	bitW                    = 16
 	bitH                    = 18
 	dataWidth               = 64
-	maxRow                  = 5
+	maxDataWidth            = 256
+	minWidth                = 1080
+	visibleRows             = 8
+	analyzeCols             = 6
 	Row                     = 1
-	DisplayNumW             = bitW * 6 * int(dataWidth/32)
+	DisplayNumW             = NumWidth(dataWidth)
 	ShiftNumW               = bitW
 	ButtonW                 = bitW * 2
-	WIDTH                   = dataWidth*bitW + ButtonW*5 + ShiftNumW + DisplayNumW + (dataWidth/4*6+1)*pad
+	menuW                   = 14
+	statsW                  = 0
+	WIDTH                   = LayoutWidth(dataWidth)
 	HEIGHT                  = bitW + Row*bitH + pad*(3+Row) + 28
-	maxHeight               = bitW + maxRow*bitH + pad*(3+maxRow) + 42 + bitH
-	MaxNum, _               = big.NewInt(0).SetString(strings.Repeat("1", dataWidth), 2)
+	fieldH                  = 0
+	diffH                   = 0
+	minHeight               = HEIGHT
+	maxHeight               = HEIGHT
 	user32DLL               = syscall.NewLazyDLL("User32.dll")
 	procGetSystemMetrics    = user32DLL.NewProc("GetSystemMetrics")
 	procGetSystemMenu       = user32DLL.NewProc("GetSystemMenu")
@@ -105,6 +131,15 @@ func NewBox(boxType fltk.BoxType, x, y, w, h, labelSize int, label string, bgCol
 	return box
 }
 
+func NewMenuButton(x, y, w, h int, label string) *fltk.MenuButton {
+	button := fltk.NewMenuButton(x, y, w, h, label)
+	button.SetBox(fltk.GLEAM_UP_BOX)
+	button.ClearVisibleFocus()
+	button.SetLabelSize(12)
+	button.SetLabelFont(fltk.HELVETICA)
+	return button
+}
+
 func NewGroup(x, y, w, h int) *fltk.Group {
 	group := fltk.NewGroup(x, y, w, h)
 	return group
@@ -119,10 +154,113 @@ func NewRadioRoundButton(x, y, w, h, base int, label string, f func(int) func())
 
 func ParseHeight(row int) int {
 	if row == 1 {
-		return pad*2 + 28 + bitW
+		return pad*2 + 28 + bitW + fieldH
 	} else {
-		return (row-1)*bitH + pad*(row+1) + 28 + bitW
+		return (row-1)*bitH + pad*(row+1) + 28 + bitW + fieldH
+	}
+}
+
+// RowsH is the height of the rows area, which scrolls past visibleRows rows.
+func RowsH() int {
+	if Row > visibleRows {
+		return visibleRows * (bitH + pad)
+	}
+	return Row * (bitH + pad)
+}
+
+// AnalyzeH is the height of the analyze area: the input and one result per
+// row, analyzeCols to a line.
+func AnalyzeH() int {
+	return (Row + analyzeCols) / analyzeCols * (bitH + 14)
+}
+
+// SetRows sets the row count and the window height that goes with it.
+func SetRows(rows int, analyze bool) {
+	Row = rows
+	HEIGHT = bitW + pad*3 + 28 + fieldH + RowsH() + diffH
+	if analyze {
+		HEIGHT += AnalyzeH()
+	}
+	minHeight = HEIGHT
+	maxHeight = HEIGHT
+}
+
+func NumWidth(width int) int {
+	w := bitW * 6 * width / 32
+	if w < bitW*4 {
+		return bitW * 4
+	}
+	return w
+}
+
+func BitX(c, width int) int {
+	g := (c + (4-width%4)%4) / 4
+	return c*bitW + g*pad*2 + (c+1)*pad
+}
+
+func LayoutWidth(width int) int {
+	w := BitX(width, width) + NumWidth(width) + ButtonW*9 + ShiftNumW + bitW*3 + menuW + statsW + pad*6
+	if w < minWidth {
+		return minWidth
+	}
+	return w
+}
+
+func SetDataWidth(width int) {
+	dataWidth = width
+	DisplayNumW = NumWidth(width)
+	WIDTH = LayoutWidth(width)
+}
+
+// SetStats makes room for the stats strip at the end of each row.
+func SetStats(show bool) {
+	statsW = 0
+	if show {
+		statsW = 230
 	}
+	WIDTH = LayoutWidth(dataWidth)
+}
+
+// SetFieldStrip and SetDiffRow take effect with the next SetRows.
+func SetFieldStrip(show bool) {
+	fieldH = 0
+	if show {
+		fieldH = bitH + pad
+	}
+}
+
+// SetDiffRow makes room for the difference row below the rows.
+func SetDiffRow(show bool) {
+	diffH = 0
+	if show {
+		diffH = bitH + pad
+	}
+}
+
+func FieldTip(f *regmodel.Field) string {
+	tip := fmt.Sprintf("%s [%s] %s", f.Name, f.Range(), f.AccessType())
+	if f.Hardware != "" {
+		tip += " hw:" + f.Hardware
+	}
+	if f.Description != "" {
+		tip += "\n" + f.Description
+	}
+	for _, e := range f.Enums {
+		tip += fmt.Sprintf("\n  %s = %s", e.Name, e.Value)
+	}
+	return tip
+}
+
+func FieldText(reg *regmodel.Register, f *regmodel.Field, base int) string {
+	return EnumText(f, reg.FieldValue(f), base)
+}
+
+// EnumText is value of field f as a number, with its enum name if it has one.
+func EnumText(f *regmodel.Field, value *big.Int, base int) string {
+	if e := f.Enum(value); e != nil {
+		return fmt.Sprintf("%s (%s)", e.Name, regmodel.FormatNumber(value, base))
+	}
+	return regmodel.FormatNumber(value, base)
 }
 
 func SetOntop(ontop bool) {
@@ -153,13 +291,6 @@ type Bit struct {
 	*fltk.Box
 }
 
-func (b *Bit) Click() {
-	val := b.Label()
-	str := textMap[val]
-	b.SetLabel(str)
-	b.SetColor(bitColorMap[str])
-}
-
 func NewBit(x, y, w, h int) *Bit {
 	bit := NewBox(fltk.BORDER_BOX, x, y, w, h, 14, "0", fltk.WHITE)
 	return &Bit{bit}
@@ -175,28 +306,161 @@ type BitRow struct {
 	reverse         *fltk.Button
 	invert          *fltk.Button
 	clear           *fltk.Button
+	reset           *fltk.Button
+	menu            *fltk.MenuButton
+	width           *fltk.Spinner
+	name            *fltk.Input
+	grip            *fltk.Box
+	stats           *fltk.Output
 	base            int
+	simulate        bool
+	showReset       bool
+	lookup          regmodel.Lookup
+	show            int
+	q               regmodel.QFormat
+	note            string
+	tag             int
+	action          string
+	changed         *big.Int
 	lastShiftNum    int64
 	shiftNumDisplay *fltk.Box
-	bigInt          big.Int
+	reg             *regmodel.Register
 }
 
-func (b *BitRow) GetBitString() []string {
-	bitList := make([]string, dataWidth)
-	for c := 0; c < dataWidth; c++ {
-		bitList[c] = b.bitLocs[c].Label()
+// ways a row shows its value in the number input
+const (
+	showUnsigned = iota
+	showSigned
+	showFloat
+	showBFloat16
+	showFixed
+)
+
+var showNames = []string{"无符号", "有符号", "浮点", "bfloat16", "定点"}
+
+// Float is the floating point format the row is shown in, if any.
+func (b *BitRow) Float() (regmodel.FloatFormat, bool) {
+	switch b.show {
+	case showFloat:
+		return regmodel.FloatFor(b.reg.Width(), false)
+	case showBFloat16:
+		if b.reg.Width() == 16 {
+			return regmodel.BFloat16, true
+		}
+	}
+	return regmodel.FloatFormat{}, false
+}
+
+// Fixed is the fixed point format the row is shown in, if any; Q1.n over the
+// row width unless one was chosen.
+func (b *BitRow) Fixed() (regmodel.QFormat, bool) {
+	if b.show != showFixed {
+		return regmodel.QFormat{}, false
+	}
+	q := b.q
+	if q.Width() == 0 {
+		q = regmodel.QFormat{Signed: true, Int: 1, Frac: b.reg.Width() - 1}
+	}
+	return q, q.Width() <= b.reg.Width()
+}
+
+// QuantizeNote reports how the real number text was rounded into the row's
+// fixed point format.
+func (b *BitRow) QuantizeNote(text string) string {
+	q, ok := b.Fixed()
+	x, err := regmodel.ParseReal(text)
+	if !ok || err != nil {
+		return ""
 	}
-	return bitList
+	bits, saturated := q.Quantize(x)
+	note := "量化误差 " + regmodel.RealText(new(big.Rat).Sub(q.Value(bits), x), q.Frac+6)
+	if saturated {
+		note += " (超出范围, 已饱和)"
+	}
+	return note
 }
 
 func (b *BitRow) SetNum() {
-	b.num.SetValue(b.bigInt.Text(b.base))
+	if q, ok := b.Fixed(); ok {
+		b.num.SetValue(q.Text(b.reg.Value()))
+		return
+	}
+	if f, ok := b.Float(); ok {
+		b.num.SetValue(f.Decode(b.reg.Value()).String())
+		return
+	}
+	if b.show == showSigned && b.base == 10 {
+		b.num.SetValue(b.reg.Signed().String())
+		return
+	}
+	b.num.SetValue(b.reg.Text(b.base))
+}
+
+func (b *BitRow) SetShow(show int, fn, fnc func()) func() {
+	return func() {
+		b.show = show
+		b.action = "显示方式"
+		b.UpdateBitNum()
+		fn()
+		fnc()
+	}
+}
+
+// SetTag marks the row with one of tagColors, which also tints its set bits.
+func (b *BitRow) SetTag(tag int, fnc func()) func() {
+	return func() {
+		b.tag = tag
+		b.action = "颜色标记"
+		b.UpdateName()
+		b.UpdateBit()
+		fnc()
+	}
+}
+
+// UpdateName shows the tag colour and the note on the name input.
+func (b *BitRow) UpdateName() {
+	if b.tag > 0 {
+		b.name.SetColor(tagColors[b.tag])
+	} else {
+		b.name.SetColor(fltk.BACKGROUND2_COLOR)
+	}
+	tip := "行名"
+	if b.note != "" {
+		tip += "\n备注: " + b.note
+	}
+	b.name.SetTooltip(tip)
+	b.name.Redraw()
+}
+
+// UpdateTip puts the other readings of the value in the number input's
+// tooltip.
+func (b *BitRow) UpdateTip() {
+	b.num.SetTooltip(b.Tip())
+	b.menu.SetTooltip("更多 (显示" + showNames[b.show] + ")")
+}
+
+func (b *BitRow) Tip() string {
+	tip := ""
+	f, float := b.Float()
+	q, fixed := b.Fixed()
+	switch {
+	case float:
+		tip = f.Decode(b.reg.Value()).Detail()
+	case fixed:
+		min, max := q.Range()
+		tip = fmt.Sprintf("%s\n分辨率 %s\n范围 [%s, %s]", q, regmodel.RealText(q.Resolution(), q.Frac), regmodel.RealText(min, q.Frac), regmodel.RealText(max, q.Frac))
+	case b.show == showSigned:
+		tip = fmt.Sprintf("无符号 %s\n有符号 %s", b.reg.Text(10), b.reg.Signed())
+	case b.show != showUnsigned:
+		tip = fmt.Sprintf("%d位没有对应的%s格式", b.reg.Width(), showNames[b.show])
+	}
+	return tip
 }
 
 func (b *BitRow) GetCurrentNum() int64 {
-	shiftNum, err := strconv.ParseInt(b.shiftNum.Value(), 10, dataWidth*2)
+	shiftNum, err := strconv.ParseUint(b.shiftNum.Value(), 10, 16)
 	if err == nil {
-		b.lastShiftNum = shiftNum
+		b.lastShiftNum = int64(shiftNum)
 	} else {
 		if b.lastShiftNum != 0 {
 			b.shiftNum.SetValue(fmt.Sprint(b.lastShiftNum))
@@ -208,26 +472,114 @@ func (b *BitRow) GetCurrentNum() int64 {
 }
 
 func (b *BitRow) UpdateBit() {
-	bins := fmt.Sprintf("%0*s", dataWidth, b.bigInt.Text(2))
+	width := b.reg.Width()
+	diff := b.reg.ResetDiff()
 	for c := 0; c < dataWidth; c++ {
-		s := string(bins[c])
+		ix := dataWidth - 1 - c
+		if ix >= width {
+			b.bitLocs[c].Hide()
+			continue
+		}
+		s := fmt.Sprint(b.reg.Bit(ix))
 		b.bitLocs[c].SetLabel(s)
-		b.bitLocs[c].SetColor(bitColorMap[s])
+		if b.showReset && diff.Bit(ix) == 1 {
+			b.bitLocs[c].SetLabelColor(headerColorMap[14])
+			b.bitLocs[c].SetLabelFont(fltk.HELVETICA_BOLD)
+		} else {
+			b.bitLocs[c].SetLabelColor(fltk.BLACK)
+			b.bitLocs[c].SetLabelFont(fltk.HELVETICA)
+		}
+		if b.changed != nil && b.changed.Bit(ix) == 1 {
+			b.bitLocs[c].SetColor(changedBitColor)
+		} else if s == "1" && b.tag > 0 {
+			b.bitLocs[c].SetColor(tagColors[b.tag])
+		} else {
+			b.bitLocs[c].SetColor(bitColorMap[s])
+		}
+		b.bitLocs[c].Show()
 	}
 }
 
-func (b *BitRow) UpdateNum() {
-	binStr := b.GetBitString()
-	str := strings.Join(binStr, "")
-	b.bigInt.SetString(str, 2)
-	b.SetNum()
+func (b *BitRow) UpdateTooltips() {
+	for c := 0; c < dataWidth; c++ {
+		if f := b.reg.FieldAt(dataWidth - 1 - c); f != nil {
+			b.bitLocs[c].SetTooltip(FieldTip(f))
+		} else {
+			b.bitLocs[c].SetTooltip("")
+		}
+	}
 }
 
 func (b *BitRow) UpdateBitNum() {
+	b.ClearMarks()
 	b.SetNum()
 	b.UpdateBit()
 }
 
+// ClearMarks drops the write result and error marks of the number input.
+func (b *BitRow) ClearMarks() {
+	b.changed = nil
+	b.UpdateTip()
+	b.num.SetColor(fltk.BACKGROUND2_COLOR)
+}
+
+// SimulateWrite applies the typed value as a register write, following the
+// access type of each field, and marks the bits the write changed.
+func (b *BitRow) SimulateWrite() {
+	v, err := b.Eval()
+	if err != nil {
+		b.ShowError(err)
+		return
+	}
+	written := regmodel.NewRegister(b.reg.Width())
+	written.SetValue(v)
+	old := b.reg.Value()
+	b.reg.SetValue(b.reg.WriteResult(written.Value()))
+	b.UpdateBitNum()
+	b.changed = new(big.Int).Xor(old, b.reg.Value())
+	b.num.SetTooltip(fmt.Sprintf("写入 %s\n结果 %s\n变化位 %s", written.Text(b.base), b.reg.Text(b.base), regmodel.FormatNumber(b.changed, b.base)))
+	b.UpdateBit()
+}
+
+// Eval evaluates the number input as an expression in the row's base; the
+// other rows are named row1, row2, ... Rows shown as floats or fixed point
+// take a real number.
+func (b *BitRow) Eval() (*big.Int, error) {
+	text := b.num.Value()
+	if strings.TrimSpace(text) == "" {
+		return new(big.Int), nil
+	}
+	if f, ok := b.Float(); ok {
+		return f.Encode(text)
+	}
+	if q, ok := b.Fixed(); ok {
+		x, err := regmodel.ParseReal(text)
+		if err != nil {
+			return nil, err
+		}
+		bits, _ := q.Quantize(x)
+		return bits, nil
+	}
+	return regmodel.EvalBase(text, b.base, b.lookup)
+}
+
+// ShowError marks the number input and puts the message in its tooltip with
+// the offending character bracketed.
+func (b *BitRow) ShowError(err error) {
+	b.num.SetColor(errorColor)
+	msg := err.Error()
+	if e, ok := err.(*regmodel.ExprError); ok {
+		text := b.num.Value()
+		end := e.Pos
+		if end < len(text) {
+			end++
+		}
+		msg += "\n" + text[:e.Pos] + "[" + text[e.Pos:end] + "]" + text[end:]
+	}
+	b.num.SetTooltip(msg)
+	b.num.Redraw()
+}
+
 func (b *BitRow) Display() {
 	b.shiftNum.Hide()
 	b.shiftNumDisplay.Show()
@@ -255,8 +607,8 @@ func (b *BitRow) DisplayClick(e fltk.Event) bool {
 func (b *BitRow) ClickLShift(fn, fnc func()) func() {
 	return func() {
 		shiftNum := b.GetCurrentNum()
-		b.bigInt.Lsh(&b.bigInt, uint(shiftNum))
-		b.bigInt.And(&b.bigInt, MaxNum)
+		b.reg.Lsh(uint(shiftNum))
+		b.action = fmt.Sprintf("左移%d", shiftNum)
 		b.UpdateBitNum()
 		b.Display()
 		fn()
@@ -267,8 +619,33 @@ func (b *BitRow) ClickLShift(fn, fnc func()) func() {
 func (b *BitRow) ClickRShift(fn, fnc func()) func() {
 	return func() {
 		shiftNum := b.GetCurrentNum()
-		b.bigInt.Rsh(&b.bigInt, uint(shiftNum))
-		b.bigInt.And(&b.bigInt, MaxNum)
+		b.reg.Rsh(uint(shiftNum))
+		b.action = fmt.Sprintf("右移%d", shiftNum)
+		b.UpdateBitNum()
+		fn()
+		fnc()
+		b.Display()
+	}
+}
+
+// shifts are the shift and rotate operations of the row menu, by the count
+// in the row's shift input.
+var shifts = []struct {
+	name string
+	op   func(*regmodel.Register, uint)
+}{
+	{"左移", (*regmodel.Register).Lsh},
+	{"右移", (*regmodel.Register).Rsh},
+	{"循环左移", (*regmodel.Register).Rol},
+	{"循环右移", (*regmodel.Register).Ror},
+	{"算术右移", (*regmodel.Register).Asr},
+}
+
+func (b *BitRow) ClickShift(name string, op func(*regmodel.Register, uint), fn, fnc func()) func() {
+	return func() {
+		shiftNum := b.GetCurrentNum()
+		op(b.reg, uint(shiftNum))
+		b.action = fmt.Sprintf("%s%d", name, shiftNum)
 		b.UpdateBitNum()
 		fn()
 		fnc()
@@ -278,18 +655,34 @@ func (b *BitRow) ClickRShift(fn, fnc func()) func() {
 
 func (b *BitRow) ClickReverse(fn, fnc func()) func() {
 	return func() {
-		binStr := make([]string, dataWidth)
-		for i, j := 0, dataWidth-1; i < j; i, j = i+1, j-1 {
-			h := b.bitLocs[i].Label()
-			e := b.bitLocs[j].Label()
-			b.bitLocs[i].SetLabel(e)
-			b.bitLocs[i].SetColor(bitColorMap[e])
-			b.bitLocs[j].SetLabel(h)
-			b.bitLocs[j].SetColor(bitColorMap[h])
-			binStr[i] = b.bitLocs[i].Label()
-		}
-		b.UpdateNum()
-		b.SetNum()
+		b.reg.Reverse()
+		b.action = "倒序"
+		b.UpdateBitNum()
+		fn()
+		fnc()
+		b.Display()
+	}
+}
+
+// swaps are the byte order operations of the row menu: unit-bit pieces
+// reversed inside group-bit groups
+var swaps = []struct {
+	name        string
+	unit, group int
+}{
+	{"16位字节交换", 8, 16},
+	{"32位字节交换", 8, 32},
+	{"64位字节交换", 8, 64},
+	{"半字交换", 16, 32},
+	{"字交换", 32, 64},
+	{"半字节交换", 4, 8},
+}
+
+func (b *BitRow) ClickSwap(name string, unit, group int, fn, fnc func()) func() {
+	return func() {
+		b.reg.Swap(unit, group)
+		b.action = name
+		b.UpdateBitNum()
 		fn()
 		fnc()
 		b.Display()
@@ -301,26 +694,60 @@ func (b *BitRow) KeyType(fn, fnc func()) func(fltk.Event) bool {
 		if e == fltk.Event(fltk.LeftMouse) {
 			b.Display()
 		}
-		if e == fltk.KEYUP {
-			old := b.bigInt.Text(b.base)
-			str := b.num.Value()
-			if str == "" {
-				b.bigInt.SetString("0", b.base)
-			} else {
-				bigInt, ok := b.bigInt.SetString(str, b.base)
-				if !ok || bigInt.Cmp(MaxNum) == 1 {
-					b.bigInt.SetString(old, b.base)
-				}
+		if e != fltk.KEYUP {
+			return false
+		}
+		key := fltk.EventKey()
+		enter := key == keyEnter || key == keyKPEnter
+		if b.simulate {
+			if !enter {
+				return true
 			}
-			b.UpdateBit()
-			b.SetNum()
+			b.SimulateWrite()
+			b.action = "模拟写"
 			fn()
 			fnc()
 			b.Display()
-			b.SetNum()
 			return true
 		}
-		return false
+		// plain numbers take effect while typing; expressions, reals and
+		// prefixed literals such as 0b1010 in base 16 on Enter
+		_, float := b.Float()
+		_, fixed := b.Fixed()
+		if float || fixed || regmodel.Prefixed(b.num.Value()) || b.reg.SetString(b.num.Value(), b.base) != nil {
+			text := b.num.Value()
+			v, err := b.Eval()
+			switch {
+			case err != nil && enter:
+				b.ShowError(err)
+			case err != nil:
+				b.num.SetTooltip(err.Error())
+			case !enter:
+				b.num.SetColor(fltk.BACKGROUND2_COLOR)
+				tip := "= " + regmodel.FormatNumber(new(big.Int).And(v, b.reg.MaxNum()), b.base)
+				if note := b.QuantizeNote(text); note != "" {
+					tip += "\n" + note
+				}
+				b.num.SetTooltip(tip)
+			}
+			if err != nil || !enter {
+				return true
+			}
+			b.reg.SetValue(v)
+			b.UpdateBitNum()
+			if fixed {
+				b.num.SetTooltip(b.Tip() + "\n" + b.QuantizeNote(text))
+			}
+		} else {
+			// keep the text as typed, e.g. binary digits without separators
+			b.ClearMarks()
+			b.UpdateBit()
+		}
+		b.action = "输入"
+		fn()
+		fnc()
+		b.Display()
+		return true
 	}
 }
 
@@ -338,14 +765,14 @@ func (b *BitRow) ShiftNumEvent(e fltk.Event) bool {
 	return false
 }
 
-func (b *BitRow) Click(fn, fnc, fun func()) func(fltk.Event) bool {
+func (b *BitRow) Click(c int, fn, fnc func()) func(fltk.Event) bool {
 	return func(e fltk.Event) bool {
 		if e == fltk.Event(fltk.LeftMouse) {
+			b.reg.Toggle(dataWidth - 1 - c)
+			b.action = fmt.Sprintf("翻转位%d", dataWidth-1-c)
+			b.UpdateBitNum()
 			fn()
 			fnc()
-			fun()
-			b.UpdateNum()
-			b.SetNum()
 			b.Display()
 			return true
 		}
@@ -355,11 +782,9 @@ func (b *BitRow) Click(fn, fnc, fun func()) func(fltk.Event) bool {
 
 func (b *BitRow) ClickClear(fn, fnc func()) func() {
 	return func() {
-		for c := 0; c < dataWidth; c++ {
-			b.bitLocs[c].SetLabel("0")
-			b.bitLocs[c].SetColor(bitColorMap["0"])
-		}
-		b.num.SetValue("0")
+		b.reg.Clear()
+		b.action = "清空"
+		b.UpdateBitNum()
 		if fn != nil {
 			fn()
 		}
@@ -370,33 +795,75 @@ func (b *BitRow) ClickClear(fn, fnc func()) func() {
 
 func (b *BitRow) ClickInvert(fn, fnc func()) func() {
 	return func() {
-		for c := 0; c < dataWidth; c++ {
-			b.bitLocs[c].Click()
+		b.reg.Invert()
+		b.action = "转换"
+		b.UpdateBitNum()
+		fn()
+		fnc()
+		b.Display()
+	}
+}
+
+func (b *BitRow) ClickReset(fn, fnc func()) func() {
+	return func() {
+		b.reg.LoadReset()
+		b.action = "复位"
+		b.UpdateBitNum()
+		fn()
+		fnc()
+		b.Display()
+	}
+}
+
+func (b *BitRow) SetReset(keep bool, fn func()) func() {
+	return func() {
+		if keep {
+			b.reg.SetReset(b.reg.Value())
+			b.action = "设置复位值"
+		} else {
+			b.reg.SetReset(nil)
+			b.action = "清除复位值"
 		}
-		b.UpdateNum()
-		b.SetNum()
+		b.UpdateReset()
+		b.UpdateBit()
+		fn()
+	}
+}
+
+func (b *BitRow) UpdateReset() {
+	if reset := b.reg.Reset(); reset != nil {
+		b.reset.SetTooltip("载入复位值 0x" + reset.Text(16))
+		b.reset.Activate()
+	} else {
+		b.reset.SetTooltip("未设置复位值")
+		b.reset.Deactivate()
+	}
+}
+
+func (b *BitRow) ChangeWidth(fn, fnc func()) func() {
+	return func() {
+		b.reg.SetWidth(int(b.width.Value()))
+		b.width.SetValue(float64(b.reg.Width()))
+		b.action = fmt.Sprintf("位宽%d", b.reg.Width())
+		b.UpdateBitNum()
 		fn()
 		fnc()
 		b.Display()
 	}
 }
 
-func NewBitRow(row int, fn, fnc func()) *BitRow {
+func NewBitRow(row int, reg *regmodel.Register, fn, fnc func()) *BitRow {
 	bitRow := new(BitRow)
 	h := ParseHeight(row)
 	group := fltk.NewGroup(0, h, WIDTH, bitH)
 	bitRow.group = group
 	bitLocs := make([]*Bit, dataWidth)
 	for c := 0; c < dataWidth; c++ {
-		n := c*bitW + c/4*pad*2 + (c+1)*pad
-		if n == 1 {
-			n = 4
-		}
-		bit := NewBit(n, h, bitW, bitH)
-		bit.SetEventHandler(bitRow.Click(bit.Click, fn, fnc))
+		bit := NewBit(BitX(c, dataWidth), h, bitW, bitH)
+		bit.SetEventHandler(bitRow.Click(c, fn, fnc))
 		bitLocs[c] = bit
 	}
-	bitsWidth := dataWidth*bitW + dataWidth/4*pad*2 + (dataWidth+1)*pad
+	bitsWidth := BitX(dataWidth, dataWidth)
 	bitRow.bitLocs = bitLocs
 	num := NewInput(bitsWidth, h, DisplayNumW, bitH, "0")
 	num.SetEventHandler(bitRow.KeyType(fn, fnc))
@@ -417,10 +884,51 @@ func NewBitRow(row int, fn, fnc func()) *BitRow {
 	bitRow.invert = invert
 	clear := NewButton(bitsWidth+pad*6+DisplayNumW+bitW+ButtonW*2+50, h, ButtonW, bitH, "清空", bitRow.ClickClear(fn, fnc))
 	bitRow.clear = clear
+	reset := NewButton(bitsWidth+pad*7+DisplayNumW+bitW+ButtonW*3+50, h, ButtonW, bitH, "复位", bitRow.ClickReset(fn, fnc))
+	bitRow.reset = reset
+	menu := fltk.NewMenuButton(bitsWidth+pad*7+DisplayNumW+bitW+ButtonW*4+50, h, menuW, bitH)
+	menu.Add("当前值设为复位值", bitRow.SetReset(true, fnc))
+	menu.Add("清除复位值", bitRow.SetReset(false, fnc))
+	for show, name := range showNames {
+		menu.Add("显示/"+name, bitRow.SetShow(show, fn, fnc))
+	}
+	// plain shifts have their own buttons
+	for _, shift := range shifts[2:] {
+		menu.Add("移位/"+shift.name, bitRow.ClickShift(shift.name, shift.op, fn, fnc))
+	}
+	for _, swap := range swaps {
+		menu.Add("字节序/"+swap.name, bitRow.ClickSwap(swap.name, swap.unit, swap.group, fn, fnc))
+	}
+	for tag, name := range tagNames {
+		menu.Add("颜色标记/"+name, bitRow.SetTag(tag, fnc))
+	}
+	bitRow.menu = menu
+	width := fltk.NewSpinner(bitsWidth+pad*8+DisplayNumW+bitW+ButtonW*4+menuW+50, h, ButtonW+bitW, bitH)
+	width.SetType(fltk.SPINNER_INT_INPUT)
+	width.SetMinimum(1)
+	width.SetMaximum(float64(dataWidth))
+	width.SetStep(8)
+	width.SetValue(float64(reg.Width()))
+	width.SetTooltip("本行位宽")
+	width.SetCallback(bitRow.ChangeWidth(fn, fnc))
+	bitRow.width = width
+	name := NewInput(bitsWidth+pad*9+DisplayNumW+bitW*2+ButtonW*5+menuW+50, h, ButtonW*2, bitH, "")
+	name.SetTooltip("行名")
+	bitRow.name = name
+	grip := NewBox(fltk.BORDER_BOX, bitsWidth+pad*10+DisplayNumW+bitW*2+ButtonW*7+menuW+50, h, bitW, bitH, 12, "≡", fltk.BACKGROUND_COLOR)
+	grip.SetTooltip("拖动调整行顺序")
+	bitRow.grip = grip
+	if statsW > 0 {
+		stats := NewOutput(bitsWidth+pad*11+DisplayNumW+bitW*3+ButtonW*7+menuW+50, h, statsW-pad, bitH, "")
+		bitRow.stats = stats
+	}
 	bitRow.base = 16
 	shiftDisplay.SetEventHandler(bitRow.DisplayClick)
 	bitRow.shiftNumDisplay = shiftDisplay
-	bitRow.bigInt = *big.NewInt(0)
+	bitRow.reg = reg
+	bitRow.UpdateReset()
+	bitRow.UpdateBitNum()
+	bitRow.UpdateTooltips()
 	group.End()
 	return bitRow
 }
@@ -446,74 +954,561 @@ func (h Headers) UpdateHeader(c, size int) {
 func NewHeaders() Headers {
 	headers := make([]*Header, dataWidth)
 	for c := 0; c < dataWidth; c++ {
-		n := c*bitW + (c/4)*pad*2 + (c+1)*pad
-		if n == 1 {
-			n = 4
-		}
-		head := NewHeader(n, pad*2+28, bitW, bitW, dataWidth-1-c)
+		head := NewHeader(BitX(c, dataWidth), pad*2+28+fieldH, bitW, bitW, dataWidth-1-c)
 		headers[c] = head
 	}
 	return headers
 }
 
-type ColorSelect struct {
-	group  *fltk.Group
-	colors []*fltk.Button
-	index  int
+type FieldStrip []*fltk.Box
+
+func NewFieldStrip(layout *regmodel.Layout) FieldStrip {
+	var strip FieldStrip
+	if layout == nil {
+		return strip
+	}
+	for i := range layout.Fields {
+		f := &layout.Fields[i]
+		if f.Lsb >= dataWidth {
+			continue
+		}
+		msb := f.Msb
+		if msb >= dataWidth {
+			msb = dataWidth - 1
+		}
+		x := BitX(dataWidth-1-msb, dataWidth)
+		w := BitX(dataWidth-1-f.Lsb, dataWidth) + bitW - x
+		box := NewBox(fltk.BORDER_BOX, x, pad*2+28, w, bitH, 10, f.Name, fieldColorMap[i%2])
+		box.SetAlign(fltk.ALIGN_CENTER | fltk.ALIGN_INSIDE | fltk.ALIGN_CLIP)
+		box.SetTooltip(FieldTip(f))
+		strip = append(strip, box)
+	}
+	return strip
 }
 
-func (c *ColorSelect) Click(m *MainForm) func() {
-	return func() {
-		var color fltk.Color
-		for _, box := range c.colors {
-			if box.HasFocus() {
-				color = box.Color()
-				break
-			}
+// DiffRow shows the difference mask of the first two rows, row1 ^ row2,
+// under the last row.
+type DiffRow struct {
+	group *fltk.Group
+	bits  []*Bit
+	num   *fltk.Output
+	label *fltk.Box
+}
+
+func NewDiffRow(y int) *DiffRow {
+	diff := new(DiffRow)
+	group := fltk.NewGroup(0, y, WIDTH, bitH)
+	diff.bits = make([]*Bit, dataWidth)
+	for c := 0; c < dataWidth; c++ {
+		diff.bits[c] = NewBit(BitX(c, dataWidth), y, bitW, bitH)
+	}
+	bitsWidth := BitX(dataWidth, dataWidth)
+	diff.num = NewOutput(bitsWidth, y, DisplayNumW, bitH, "")
+	diff.label = NewBox(fltk.FLAT_BOX, bitsWidth+pad+DisplayNumW, y, 80, bitH, 12, "差异 行1^行2", fltk.BACKGROUND_COLOR)
+	diff.label.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE)
+	group.End()
+	diff.group = group
+	return diff
+}
+
+func (d *DiffRow) Update(v *big.Int, width, base int) {
+	for c := 0; c < dataWidth; c++ {
+		ix := dataWidth - 1 - c
+		if ix >= width {
+			d.bits[c].Hide()
+			continue
 		}
-		if c.index == 0 {
-			m.BitColorBox.SetColor(color)
-			m.BitColorBox.Redraw()
-			bitColorMap["1"] = color
-			for r := 0; r < Row; r++ {
-				bitRow := m.BitRows[r]
-				bitRow.UpdateBit()
-			}
+		s := fmt.Sprint(v.Bit(ix))
+		d.bits[c].SetLabel(s)
+		d.bits[c].SetColor(bitColorMap[s])
+		if s == "1" {
+			d.bits[c].SetLabelColor(headerColorMap[14])
 		} else {
-			headerColorMap[14] = color
-			m.HeaderColorBox.SetColor(color)
-			m.HeaderColorBox.Redraw()
-			m.Updateheaders()
+			d.bits[c].SetLabelColor(fltk.BLACK)
 		}
-		c.group.Hide()
+		d.bits[c].Show()
 	}
+	d.num.SetValue(regmodel.FormatNumber(v, base))
+	d.group.Redraw()
 }
 
-func NewColorSelect(m *MainForm) *ColorSelect {
-	group := fltk.NewGroup(pad*10+307, 0, WIDTH-pad*10-504, 30)
-	colorSelect := new(ColorSelect)
-	colors := make([]*fltk.Button, 52)
-	colorCode := []fltk.Color{
-		fltk.BACKGROUND_COLOR, fltk.INACTIVE_COLOR, fltk.DARK_BLUE,
-		fltk.DARK_CYAN, fltk.DARK_GREEN, fltk.DARK_MAGENTA,
-		fltk.DARK_RED, fltk.DARK_YELLOW, fltk.LIGHT2,
-		fltk.SELECTION_COLOR, fltk.BLUE, fltk.CYAN,
-		fltk.GREEN, fltk.MAGENTA, fltk.RED, fltk.Color(0xFFA50000),
-		fltk.YELLOW, fltk.Color(0x008B8B00), fltk.Color(0x00CED100),
-		fltk.Color(0x00FFFF00), fltk.Color(0xFFE4E100), fltk.Color(0xFF8C0000),
-		fltk.Color(0x1E90FF00), fltk.Color(0x20B2AA00), fltk.Color(0x2F4F4F00),
-		fltk.Color(0x483D8B00), fltk.Color(0x4B008200), fltk.Color(0xBA55D300),
-		fltk.Color(0x6B8E2300), fltk.Color(0xADFF2F00), fltk.Color(0x80008000),
-		fltk.Color(0x8A2BE200), fltk.Color(0x8B451300), fltk.Color(0x8FBC8F00),
-		fltk.Color(0x90EE9000), fltk.Color(0x9932CC00), fltk.Color(0x9400D300),
-		fltk.Color(0x9ACD3200), fltk.Color(0xA0522D00), fltk.Color(0xA52A2A00),
-		fltk.Color(0xADD8E600), fltk.Color(0xFFD70000),
-		// fltk.Color(0xB8860B00),
-		// fltk.Color(0x00800000), fltk.Color(0xC7158500), fltk.Color(0x00BFFF00),
-		// fltk.Color(0x00Fe7e00), fltk.Color(0xEEE8AA00), fltk.Color(0x69696900),
-		// fltk.Color(0xFFFFF000), fltk.Color(0xFFEFD500), fltk.Color(0xB2222200),
+type LayoutEditor struct {
+	window *fltk.Window
+	buffer *fltk.TextBuffer
+	msg    *fltk.Box
+}
+
+func (e *LayoutEditor) Apply(m *MainForm) func() {
+	return func() {
+		layout, err := regmodel.ParseLayout(e.buffer.Text(), dataWidth)
+		if err != nil {
+			e.msg.SetLabel(err.Error())
+			e.msg.SetLabelColor(fltk.RED)
+			e.msg.Redraw()
+			return
+		}
+		e.msg.SetLabel("")
+		e.window.Hide()
+		m.SetLayout(layout)
 	}
-	idx := 0
+}
+
+func NewLayoutEditor(m *MainForm) *LayoutEditor {
+	w, h := 420, 300
+	editor := new(LayoutEditor)
+	win := fltk.NewWindow(w, h, "位域定义")
+	buffer := fltk.NewTextBuffer()
+	text := fltk.NewTextEditor(pad*2, pad*2, w-pad*4, h-bitH-pad*6)
+	text.SetBuffer(buffer)
+	msg := NewBox(fltk.FLAT_BOX, pad*2, h-bitH-pad*2, w-ButtonW*2-pad*6, bitH, 12, "", fltk.BACKGROUND_COLOR)
+	msg.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE | fltk.ALIGN_CLIP)
+	msg.SetTooltip("每行一个位域: 名称 高位[:低位] [RW/RO/WO/W1C/W1S/RC/RSV...] 描述")
+	NewButton(w-ButtonW*2-pad*2, h-bitH-pad*2, ButtonW*2, bitH, "应用", editor.Apply(m))
+	win.Resizable(text)
+	win.End()
+	editor.window = win
+	editor.buffer = buffer
+	editor.msg = msg
+	return editor
+}
+
+// NoteEditor edits the free-text note of one row.
+type NoteEditor struct {
+	window *fltk.Window
+	reg    *regmodel.Register
+	buffer *fltk.TextBuffer
+}
+
+func NewNoteEditor(m *MainForm) *NoteEditor {
+	w, h := 360, 160
+	editor := new(NoteEditor)
+	win := fltk.NewWindow(w, h, "备注")
+	buffer := fltk.NewTextBuffer()
+	text := fltk.NewTextEditor(pad*2, pad*2, w-pad*4, h-bitH-pad*6)
+	text.SetBuffer(buffer)
+	NewButton(w-ButtonW*2-pad*2, h-bitH-pad*2, ButtonW*2, bitH, "保存", m.SaveNote)
+	win.Resizable(text)
+	win.End()
+	editor.window = win
+	editor.buffer = buffer
+	return editor
+}
+
+// TimelineView lists the values a row held, oldest first, each with the
+// fields that changed from the value before it. Clicking an entry loads that
+// value into the row.
+type TimelineView struct {
+	window  *fltk.Window
+	reg     *regmodel.Register
+	browser *fltk.HoldBrowser
+}
+
+func NewTimelineView(m *MainForm, reg *regmodel.Register) *TimelineView {
+	w, h := 600, 320
+	view := &TimelineView{reg: reg}
+	win := fltk.NewWindow(w, h, "值记录")
+	browser := fltk.NewHoldBrowser(0, 0, w, h)
+	browser.SetColumnWidths(70, 160, 90, 0)
+	browser.SetCallback(func() {
+		if line := browser.Value(); line > 0 {
+			m.RestoreValue(reg, line-1)
+		}
+	})
+	win.Resizable(browser)
+	win.End()
+	view.window = win
+	view.browser = browser
+	return view
+}
+
+func (v *TimelineView) Update(timeline *regmodel.Timeline, base int) {
+	v.browser.Clear()
+	records := timeline.Records()
+	for i, record := range records {
+		change := ""
+		if i > 0 {
+			change = ChangeText(v.reg.Layout(), records[i-1].Value, record.Value, base)
+		}
+		v.browser.Add(fmt.Sprintf("%s\t%s\t@.%s\t@.%s", record.Time.Format("15:04:05"),
+			regmodel.FormatNumber(record.Value, base), record.Action, change))
+	}
+	v.browser.SetBottomLine(len(records))
+}
+
+// ChangeText describes what changed from one value of a row to the next:
+// the fields of layout with their old and new values, then the changed bits
+// outside any field.
+func ChangeText(layout *regmodel.Layout, from, to *big.Int, base int) string {
+	changes, other := regmodel.Changes(layout, from, to)
+	var parts []string
+	for _, c := range changes {
+		parts = append(parts, fmt.Sprintf("%s: %s→%s", c.Field.Name, EnumText(c.Field, c.From, base), EnumText(c.Field, c.To, base)))
+	}
+	var bits []string
+	for i := other.BitLen() - 1; i >= 0; i-- {
+		if other.Bit(i) == 0 {
+			continue
+		}
+		if len(bits) == 16 {
+			bits = append(bits, "...")
+			break
+		}
+		bits = append(bits, fmt.Sprint(i))
+	}
+	if len(bits) > 0 {
+		parts = append(parts, "位"+strings.Join(bits, ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BaseView shows the value of one row in every base at once, in outputs the
+// text can be copied from.
+type BaseView struct {
+	window  *fltk.Window
+	reg     *regmodel.Register
+	outputs []*fltk.Output
+	signed  *fltk.Output
+	radix   *fltk.Spinner
+	custom  *fltk.Output
+}
+
+var viewBases = []int{16, 10, 8, 2, 36}
+
+func NewBaseView(reg *regmodel.Register) *BaseView {
+	labelW := 70
+	w, h := 460, (len(viewBases)+2)*(bitH+pad*2)+pad*2
+	view := &BaseView{reg: reg}
+	win := fltk.NewWindow(w, h, "所有进制")
+	y := pad * 2
+	for _, base := range viewBases {
+		output := NewOutput(labelW, y, w-labelW-pad*2, bitH, fmt.Sprintf("%d进制", base))
+		output.SetAlign(fltk.ALIGN_LEFT)
+		view.outputs = append(view.outputs, output)
+		y += bitH + pad*2
+	}
+	view.signed = NewOutput(labelW, y, w-labelW-pad*2, bitH, "有符号")
+	view.signed.SetAlign(fltk.ALIGN_LEFT)
+	y += bitH + pad*2
+	radix := fltk.NewSpinner(pad*2, y, labelW-pad*4, bitH)
+	radix.SetType(fltk.SPINNER_INT_INPUT)
+	radix.SetMinimum(2)
+	radix.SetMaximum(36)
+	radix.SetValue(3)
+	radix.SetTooltip("任意进制")
+	radix.SetCallback(view.Update)
+	view.radix = radix
+	view.custom = NewOutput(labelW, y, w-labelW-pad*2, bitH, "")
+	win.End()
+	view.window = win
+	return view
+}
+
+func (v *BaseView) Update() {
+	value := v.reg.Value()
+	for i, base := range viewBases {
+		v.outputs[i].SetValue(regmodel.FormatNumber(value, base))
+	}
+	v.signed.SetValue(v.reg.Signed().String())
+	v.custom.SetValue(regmodel.FormatNumber(value, int(v.radix.Value())))
+}
+
+// FixedDialog picks the fixed point format of a row and quantizes real
+// numbers into it.
+type FixedDialog struct {
+	window *fltk.Window
+	reg    *regmodel.Register
+	format *fltk.Input
+	info   *fltk.Box
+	real   *fltk.Input
+	result *fltk.Box
+}
+
+func NewFixedDialog(m *MainForm) *FixedDialog {
+	labelW := 50
+	w, h := 360, 160
+	dialog := new(FixedDialog)
+	win := fltk.NewWindow(w, h, "定点格式")
+	y := pad * 2
+	format := NewInput(labelW, y, 100, bitH, "Q1.15")
+	format.SetLabel("格式")
+	format.SetAlign(fltk.ALIGN_LEFT)
+	format.SetTooltip("Qm.n有符号, UQm.n无符号, m为整数位(含符号位), n为小数位; Qn/UQn的整数位为行宽剩余位")
+	NewButton(labelW+100+pad*2, y, ButtonW*2, bitH, "应用", m.ApplyFixed)
+	y += bitH + pad*2
+	info := NewBox(fltk.FLAT_BOX, labelW, y, w-labelW-pad*2, 64, 12, "", fltk.BACKGROUND_COLOR)
+	info.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_TOP | fltk.ALIGN_INSIDE | fltk.ALIGN_CLIP)
+	y += 64 + pad*2
+	real := NewInput(labelW, y, 160, bitH, "")
+	real.SetLabel("实数")
+	real.SetAlign(fltk.ALIGN_LEFT)
+	real.SetTooltip("如 -0.25, 3e-3, 1/3")
+	NewButton(labelW+160+pad*2, y, ButtonW*3, bitH, "量化写入", m.WriteFixed)
+	y += bitH + pad*2
+	result := NewBox(fltk.FLAT_BOX, labelW, y, w-labelW-pad*2, 32, 12, "", fltk.BACKGROUND_COLOR)
+	result.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_TOP | fltk.ALIGN_INSIDE | fltk.ALIGN_CLIP)
+	win.End()
+	dialog.window = win
+	dialog.format = format
+	dialog.info = info
+	dialog.real = real
+	dialog.result = result
+	return dialog
+}
+
+// MemoryView lists the bytes of every row in memory order, lowest address
+// first, for both endiannesses.
+type MemoryView struct {
+	window  *fltk.Window
+	browser *fltk.Browser
+}
+
+// RowOpDialog combines two rows bitwise and writes the result into a row.
+type RowOpDialog struct {
+	window *fltk.Window
+	a      *fltk.Choice
+	op     *fltk.Choice
+	b      *fltk.Choice
+	target *fltk.Choice
+	diff   *fltk.CheckButton
+	result *fltk.Box
+}
+
+func NewRowOpDialog(m *MainForm) *RowOpDialog {
+	w, h := 360, 130
+	dialog := new(RowOpDialog)
+	win := fltk.NewWindow(w, h, "行运算")
+	rows := func(x, y int, label string) *fltk.Choice {
+		choice := fltk.NewChoice(x, y, 70, bitH, label)
+		choice.SetLabelSize(12)
+		return choice
+	}
+	y := pad * 2
+	a := rows(30, y, "A")
+	op := fltk.NewChoice(105, y, 140, bitH)
+	for _, rowOp := range regmodel.RowOps {
+		op.Add(rowOp.Name, nil)
+	}
+	op.SetValue(2)
+	b := rows(270, y, "B")
+	y += bitH + pad*2
+	target := rows(30, y, "写入")
+	NewButton(105, y, ButtonW*2, bitH, "计算", m.ApplyRowOp)
+	y += bitH + pad*2
+	result := NewBox(fltk.FLAT_BOX, 30, y, w-30-pad*2, 32, 12, "", fltk.BACKGROUND_COLOR)
+	result.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_TOP | fltk.ALIGN_INSIDE | fltk.ALIGN_CLIP)
+	y += 32 + pad*2
+	diff := fltk.NewCheckButton(30, y, 240, bitH, "主窗口显示差异行 (行1 ^ 行2)")
+	diff.SetLabelSize(12)
+	diff.SetValue(diffH > 0)
+	diff.SetCallback(m.ShowDiffRow)
+	win.End()
+	dialog.window = win
+	dialog.a = a
+	dialog.op = op
+	dialog.b = b
+	dialog.target = target
+	dialog.diff = diff
+	dialog.result = result
+	return dialog
+}
+
+func NewMemoryView(m *MainForm) *MemoryView {
+	w, h := 560, 220
+	view := new(MemoryView)
+	win := fltk.NewWindow(w, h, "内存字节序")
+	browser := fltk.NewBrowser(0, 0, w, h)
+	browser.SetColumnWidths(80, 60, 0)
+	win.Resizable(browser)
+	win.End()
+	win.SetCallback(func() {
+		win.Hide()
+		m.MemoryButton.SetValue(false)
+	})
+	view.window = win
+	view.browser = browser
+	return view
+}
+
+type FieldTable struct {
+	window  *fltk.Window
+	browser *fltk.HoldBrowser
+}
+
+func NewFieldTable() *FieldTable {
+	w, h := 560, 320
+	table := new(FieldTable)
+	win := fltk.NewWindow(w, h, "位域表")
+	browser := fltk.NewHoldBrowser(0, 0, w, h)
+	browser.SetColumnWidths(120, 60, 140, 80, 0)
+	win.Resizable(browser)
+	win.End()
+	table.window = win
+	table.browser = browser
+	return table
+}
+
+type RegisterBrowser struct {
+	window    *fltk.Window
+	banks     *fltk.HoldBrowser
+	registers *fltk.HoldBrowser
+	target    *fltk.Choice
+	errors    *fltk.Browser
+	regMap    *regmodel.RegisterMap
+}
+
+func (b *RegisterBrowser) Bank() *regmodel.Bank {
+	ix := b.banks.Value()
+	if b.regMap == nil || ix < 1 || ix > len(b.regMap.Banks) {
+		return nil
+	}
+	return b.regMap.Banks[ix-1]
+}
+
+func (b *RegisterBrowser) Register() *regmodel.Layout {
+	bank := b.Bank()
+	ix := b.registers.Value()
+	if bank == nil || ix < 1 || ix > len(bank.Registers) {
+		return nil
+	}
+	return bank.Registers[ix-1]
+}
+
+func (b *RegisterBrowser) SelectBank() {
+	b.registers.Clear()
+	bank := b.Bank()
+	if bank == nil {
+		return
+	}
+	for _, layout := range bank.Registers {
+		reset := ""
+		if layout.Reset != nil {
+			reset = "0x" + layout.Reset.Text(16)
+		}
+		b.registers.Add(fmt.Sprintf("%s\t0x%X\t%d\t%s", layout.Name, bank.Base+layout.Offset, layout.Width, reset))
+	}
+}
+
+func (b *RegisterBrowser) Apply(m *MainForm) func() {
+	return func() {
+		layout := b.Register()
+		if layout == nil {
+			return
+		}
+		if layout.Width > maxDataWidth {
+			b.errors.Add(fmt.Sprintf("@C1%s: 位宽%d超出上限%d", layout.Name, layout.Width, maxDataWidth))
+			return
+		}
+		m.ApplyRegister(b.target.Value(), layout)
+	}
+}
+
+func (b *RegisterBrowser) SetMap(regMap *regmodel.RegisterMap, err error) {
+	if regMap != nil {
+		b.regMap = regMap
+		b.banks.Clear()
+		for _, bank := range regMap.Banks {
+			b.banks.Add(fmt.Sprintf("%s\t0x%X", bank.Name, bank.Base))
+		}
+		if len(regMap.Banks) > 0 {
+			b.banks.SetValue(1)
+		}
+		b.SelectBank()
+	}
+	b.errors.Clear()
+	if err == nil {
+		return
+	}
+	if errs, ok := err.(regmodel.ErrorList); ok {
+		for _, e := range errs {
+			b.errors.Add("@C1" + e.Error())
+		}
+	} else {
+		b.errors.Add("@C1" + err.Error())
+	}
+}
+
+func NewRegisterBrowser(m *MainForm) *RegisterBrowser {
+	w, h := 560, 380
+	browser := new(RegisterBrowser)
+	win := fltk.NewWindow(w, h, "寄存器")
+	banks := fltk.NewHoldBrowser(0, 0, 160, h-bitH*4-pad*4)
+	banks.SetColumnWidths(90, 0)
+	banks.SetCallback(browser.SelectBank)
+	registers := fltk.NewHoldBrowser(160, 0, w-160, h-bitH*4-pad*4)
+	registers.SetColumnWidths(180, 110, 40, 0)
+	registers.SetCallback(browser.Apply(m))
+	target := fltk.NewChoice(pad*2+40, h-bitH*4-pad*2, 80, bitH, "目标行")
+	target.SetLabelSize(12)
+	NewButton(pad*4+120, h-bitH*4-pad*2, ButtonW*2, bitH, "应用", browser.Apply(m))
+	errors := fltk.NewBrowser(0, h-bitH*3, w, bitH*3)
+	win.Resizable(registers)
+	win.End()
+	browser.window = win
+	browser.banks = banks
+	browser.registers = registers
+	browser.target = target
+	browser.errors = errors
+	return browser
+}
+
+type ColorSelect struct {
+	group  *fltk.Group
+	colors []*fltk.Button
+	index  int
+}
+
+func (c *ColorSelect) Click(m *MainForm) func() {
+	return func() {
+		var color fltk.Color
+		for _, box := range c.colors {
+			if box.HasFocus() {
+				color = box.Color()
+				break
+			}
+		}
+		if c.index == 0 {
+			m.BitColorBox.SetColor(color)
+			m.BitColorBox.Redraw()
+			bitColorMap["1"] = color
+			for r := 0; r < Row; r++ {
+				bitRow := m.BitRows[r]
+				bitRow.UpdateBit()
+			}
+		} else {
+			headerColorMap[14] = color
+			m.HeaderColorBox.SetColor(color)
+			m.HeaderColorBox.Redraw()
+			m.Updateheaders()
+		}
+		c.group.Hide()
+	}
+}
+
+func NewColorSelect(m *MainForm) *ColorSelect {
+	group := fltk.NewGroup(pad*10+307, 0, WIDTH-pad*10-504, 30)
+	colorSelect := new(ColorSelect)
+	colors := make([]*fltk.Button, 52)
+	colorCode := []fltk.Color{
+		fltk.BACKGROUND_COLOR, fltk.INACTIVE_COLOR, fltk.DARK_BLUE,
+		fltk.DARK_CYAN, fltk.DARK_GREEN, fltk.DARK_MAGENTA,
+		fltk.DARK_RED, fltk.DARK_YELLOW, fltk.LIGHT2,
+		fltk.SELECTION_COLOR, fltk.BLUE, fltk.CYAN,
+		fltk.GREEN, fltk.MAGENTA, fltk.RED, fltk.Color(0xFFA50000),
+		fltk.YELLOW, fltk.Color(0x008B8B00), fltk.Color(0x00CED100),
+		fltk.Color(0x00FFFF00), fltk.Color(0xFFE4E100), fltk.Color(0xFF8C0000),
+		fltk.Color(0x1E90FF00), fltk.Color(0x20B2AA00), fltk.Color(0x2F4F4F00),
+		fltk.Color(0x483D8B00), fltk.Color(0x4B008200), fltk.Color(0xBA55D300),
+		fltk.Color(0x6B8E2300), fltk.Color(0xADFF2F00), fltk.Color(0x80008000),
+		fltk.Color(0x8A2BE200), fltk.Color(0x8B451300), fltk.Color(0x8FBC8F00),
+		fltk.Color(0x90EE9000), fltk.Color(0x9932CC00), fltk.Color(0x9400D300),
+		fltk.Color(0x9ACD3200), fltk.Color(0xA0522D00), fltk.Color(0xA52A2A00),
+		fltk.Color(0xADD8E600), fltk.Color(0xFFD70000),
+		// fltk.Color(0xB8860B00),
+		// fltk.Color(0x00800000), fltk.Color(0xC7158500), fltk.Color(0x00BFFF00),
+		// fltk.Color(0x00Fe7e00), fltk.Color(0xEEE8AA00), fltk.Color(0x69696900),
+		// fltk.Color(0xFFFFF000), fltk.Color(0xFFEFD500), fltk.Color(0xB2222200),
+	}
+	idx := 0
 	for r := 0; r < 2; r++ {
 		for c := 0; c < 21; c++ {
 			box := fltk.NewButton((pad+15)*c+pad*13+304, pad*r+r*15, 15, 15)
@@ -525,241 +1520,1300 @@ func NewColorSelect(m *MainForm) *ColorSelect {
 			idx++
 		}
 	}
-	colorSelect.colors = colors
-	colorSelect.group = group
-	group.End()
-	group.Hide()
-	return colorSelect
+	colorSelect.colors = colors
+	colorSelect.group = group
+	group.End()
+	group.Hide()
+	return colorSelect
+}
+
+type BitAnalyze struct {
+	group *fltk.Group
+	res   []*fltk.Output
+	enums []*fltk.MenuButton
+	input *fltk.Input
+}
+
+// AnalyzeSlot is where the analyze area puts its input (slot 0) and the
+// result of row c-1 (slot c), analyzeCols slots to a line.
+func AnalyzeSlot(c, top int) (int, int) {
+	width := (WIDTH-pad*2)/analyzeCols - pad
+	return c%analyzeCols*(width+pad) + pad*2, top + 12 + c/analyzeCols*(bitH+14)
+}
+
+func NewBitAnalyze() *BitAnalyze {
+	width := (WIDTH-pad*2)/analyzeCols - pad
+	bitAnalyze := new(BitAnalyze)
+	group := fltk.NewGroup(0, HEIGHT, WIDTH, AnalyzeH())
+	res := make([]*fltk.Output, Row)
+	enums := make([]*fltk.MenuButton, Row)
+	for c := 0; c <= Row; c++ {
+		x, y := AnalyzeSlot(c, HEIGHT)
+		if c == 0 {
+			input := NewInput(x, y, width, bitH, "")
+			input.SetLabel("Bit位域")
+			input.SetAlign(fltk.ALIGN_TOP)
+			input.SetLabelSize(12)
+			input.SetTooltip("位范围(如 11:0)或位域名, 后加空格和 s 按有符号数显示, 加 Q1.15/UQ8.8/Q15 按定点数显示")
+			bitAnalyze.input = input
+		} else {
+			output := NewOutput(x, y, width-bitW, bitH, fmt.Sprintf("第%d行", c))
+			res[c-1] = output
+			enum := fltk.NewMenuButton(x+width-bitW, y, bitW, bitH)
+			enum.SetTooltip("选择枚举值")
+			enum.Hide()
+			enums[c-1] = enum
+		}
+	}
+	bitAnalyze.group = group
+	bitAnalyze.res = res
+	bitAnalyze.enums = enums
+	group.End()
+	group.Hide()
+	return bitAnalyze
+}
+
+type MainForm struct {
+	Window          *fltk.Window
+	Group           *fltk.Group
+	Form            *fltk.Group
+	WidthSpin       *fltk.Spinner
+	WidthPreset     *fltk.MenuButton
+	LayoutMenu      *fltk.MenuButton
+	LayoutEditor    *LayoutEditor
+	FieldTable      *FieldTable
+	RegBrowser      *RegisterBrowser
+	BaseViews       map[*regmodel.Register]*BaseView
+	Timelines       map[*regmodel.Register]*regmodel.Timeline
+	TimelineViews   map[*regmodel.Register]*TimelineView
+	FixedDialog     *FixedDialog
+	NoteEditor      *NoteEditor
+	MemoryView      *MemoryView
+	FieldStrip      FieldStrip
+	Headers         Headers
+	BitRows         []*BitRow
+	Rows            *fltk.Scroll
+	Compare         *regmodel.Comparison
+	AddRow          *fltk.Button
+	RmRow           *fltk.Button
+	Base16          *fltk.RadioRoundButton
+	Base10          *fltk.RadioRoundButton
+	Base8           *fltk.RadioRoundButton
+	Base2           *fltk.RadioRoundButton
+	Base36          *fltk.RadioRoundButton
+	ontop           *fltk.ToggleButton
+	SimulateButton  *fltk.ToggleButton
+	ResetDiffButton *fltk.ToggleButton
+	MemoryButton    *fltk.ToggleButton
+	StatsButton     *fltk.ToggleButton
+	DiffRow         *DiffRow
+	RowOpDialog     *RowOpDialog
+	History         *regmodel.History
+	HistoryView     *HistoryView
+	action          string
+	base            int
+	MLSwitchButton  *fltk.ToggleButton
+	BitColorSel     *fltk.Button
+	BitColorBox     *fltk.Box
+	HeaderColorSel  *fltk.Button
+	HeaderColorBox  *fltk.Box
+	BitRangeParse   *fltk.ToggleButton
+	AnalyzeArea     *BitAnalyze
+	ColorSelArea    *ColorSelect
+}
+
+// FloatHeaders is the format of the first row shown as a float, whose sign,
+// exponent and fraction are marked on the headers.
+func (m *MainForm) FloatHeaders() (regmodel.FloatFormat, bool) {
+	for r := 0; r < Row; r++ {
+		if f, ok := m.BitRows[r].Float(); ok {
+			return f, true
+		}
+	}
+	return regmodel.FloatFormat{}, false
+}
+
+func (m *MainForm) Updateheaders() {
+	mask := m.Compare.DiffMask()
+	f, float := m.FloatHeaders()
+	for c := 0; c < dataWidth; c++ {
+		ix := dataWidth - 1 - c
+		color, tip := fltk.WHITE, ""
+		if float && ix < f.Width() {
+			part := 0
+			if ix < f.Frac {
+				part = 2
+			} else if ix < f.Frac+f.Exp {
+				part = 1
+			}
+			color, tip = floatColorMap[part], floatParts[part]
+		}
+		m.Headers[c].SetColor(color)
+		m.Headers[c].SetTooltip(tip)
+		if mask.Bit(ix) == 0 {
+			m.Headers.UpdateHeader(c, 11)
+		} else {
+			m.Headers.UpdateHeader(c, 14)
+		}
+	}
+}
+
+func (m *MainForm) AnalyzeAreaChange() {
+	if m.BitRangeParse.Value() {
+		top := HEIGHT - AnalyzeH()
+		m.AnalyzeArea.group.SetPosition(0, top)
+		x, y := AnalyzeSlot(0, top)
+		m.AnalyzeArea.input.SetPosition(x, y)
+		for idx, output := range m.AnalyzeArea.res {
+			x, y := AnalyzeSlot(idx+1, top)
+			output.SetPosition(x, y)
+			enum := m.AnalyzeArea.enums[idx]
+			enum.SetPosition(enum.X(), y)
+			m.UpdateAnalyzeRes(idx)
+		}
+	}
+}
+
+// Add appends a cleared row with the width and fields of the last one and
+// scrolls it into view.
+func (m *MainForm) Add() {
+	states := m.RowStates()
+	last := states[len(states)-1].reg
+	reg := regmodel.NewRegister(last.Width())
+	reg.SetLayout(last.Layout())
+	m.RebuildRows(append(states, rowState{reg: reg}))
+	m.ScrollToRow(Row - 1)
+}
+
+func (m *MainForm) Remove() {
+	if Row == 1 {
+		return
+	}
+	m.RebuildRows(m.RowStates()[:Row-1])
+}
+
+// MoveRow moves row from to position to, shifting the rows between.
+func (m *MainForm) MoveRow(from, to int) {
+	states := m.RowStates()
+	state := states[from]
+	states = append(states[:from], states[from+1:]...)
+	states = append(states[:to], append([]rowState{state}, states[to:]...)...)
+	m.action = "移动" + m.RowName(from)
+	m.RebuildRows(states)
+	m.ScrollToRow(to)
+}
+
+// RowAt is the row under window position y.
+func (m *MainForm) RowAt(y int) int {
+	r := (y - m.Rows.Y() + m.Rows.YPosition()) / (bitH + pad)
+	if r < 0 {
+		return 0
+	}
+	if r >= Row {
+		return Row - 1
+	}
+	return r
+}
+
+func (m *MainForm) ScrollToRow(r int) {
+	y := m.Rows.YPosition()
+	top := r * (bitH + pad)
+	if top < y {
+		y = top
+	} else if bottom := top + bitH + pad - RowsH(); bottom > y {
+		y = bottom
+	}
+	m.Rows.ScrollTo(0, y)
+}
+
+// DragRow moves row r to the row its grip is released over. The move
+// rebuilds the form, so it runs after the event is handled.
+func (m *MainForm) DragRow(r int) func(fltk.Event) bool {
+	return func(e fltk.Event) bool {
+		grip := m.BitRows[r].grip
+		switch e {
+		case fltk.PUSH:
+			grip.SetColor(changedBitColor)
+			grip.Redraw()
+			return true
+		case fltk.DRAG:
+			return true
+		case fltk.RELEASE:
+			grip.SetColor(fltk.BACKGROUND_COLOR)
+			grip.Redraw()
+			if to := m.RowAt(fltk.EventY()); to != r {
+				fltk.AddTimeout(0, func() {
+					m.MoveRow(r, to)
+				})
+			}
+			return true
+		}
+		return false
+	}
+}
+
+// RowName is the label of row r, or its position when it has none.
+func (m *MainForm) RowName(r int) string {
+	if name := strings.TrimSpace(m.BitRows[r].name.Value()); name != "" {
+		return name
+	}
+	return fmt.Sprintf("第%d行", r+1)
+}
+
+// SetRowChoices lists the rows in choice, plus a new row when extra is set.
+func (m *MainForm) SetRowChoices(choice *fltk.Choice, extra bool) {
+	value := choice.Value()
+	choice.Clear()
+	for r := 0; r < Row; r++ {
+		choice.Add(strings.ReplaceAll(m.RowName(r), "/", "\\/"), nil)
+	}
+	n := Row
+	if extra {
+		choice.Add("新增一行", nil)
+		n++
+	}
+	if value < 0 || value >= n {
+		value = 0
+	}
+	choice.SetValue(value)
+}
+
+func (m *MainForm) EditNote(reg *regmodel.Register) func() {
+	return func() {
+		if m.NoteEditor == nil {
+			m.NoteEditor = NewNoteEditor(m)
+		}
+		r := m.RowOf(reg)
+		editor := m.NoteEditor
+		editor.reg = reg
+		editor.buffer.SetText(m.BitRows[r].note)
+		editor.window.SetLabel(m.RowName(r) + " 备注")
+		editor.window.Show()
+	}
+}
+
+func (m *MainForm) SaveNote() {
+	editor := m.NoteEditor
+	if r := m.RowOf(editor.reg); r >= 0 {
+		m.BitRows[r].note = strings.TrimSpace(editor.buffer.Text())
+		m.BitRows[r].action = "备注"
+		m.BitRows[r].UpdateName()
+		m.UpdateFieldTable()
+		m.Record()
+	}
+	editor.window.Hide()
+}
+
+func (m *MainForm) RowRenamed() {
+	m.UpdateRowChoices()
+	m.UpdateAnalyzeArea()
+	m.AnalyzeArea.group.Redraw()
+}
+
+func (m *MainForm) UpdateRowChoices() {
+	if m.RowOpDialog != nil {
+		m.SetRowChoices(m.RowOpDialog.a, false)
+		m.SetRowChoices(m.RowOpDialog.b, false)
+		m.SetRowChoices(m.RowOpDialog.target, true)
+	}
+	if m.RegBrowser != nil {
+		m.SetRowChoices(m.RegBrowser.target, true)
+	}
+}
+
+func (m *MainForm) BaseChoise(base int) func() {
+	return func() {
+		m.base = base
+		for r := 0; r < Row; r++ {
+			m.BitRows[r].base = base
+			m.BitRows[r].SetNum()
+			m.UpdateAnalyzeRes(r)
+			m.BitRows[r].Display()
+		}
+		m.UpdateFieldTable()
+		m.UpdateDiffRow()
+		m.Record()
+	}
+}
+
+func (m *MainForm) Simulate() {
+	simulate := m.SimulateButton.Value()
+	for _, bitRow := range m.BitRows {
+		bitRow.simulate = simulate
+		bitRow.UpdateBitNum()
+	}
+}
+
+// RowValue resolves row1, row2, ... in number expressions to the values of
+// the visible rows.
+func (m *MainForm) RowValue(name string) (*big.Int, bool) {
+	if !strings.HasPrefix(strings.ToLower(name), "row") {
+		return nil, false
+	}
+	r, err := strconv.Atoi(name[3:])
+	if err != nil || r < 1 || r > Row {
+		return nil, false
+	}
+	return m.BitRows[r-1].reg.Value(), true
+}
+
+func (m *MainForm) ShowResetDiff() {
+	show := m.ResetDiffButton.Value()
+	for _, bitRow := range m.BitRows {
+		bitRow.showReset = show
+		bitRow.UpdateBit()
+	}
+}
+
+func (m *MainForm) SetOnTop() {
+	status := m.ontop.Value()
+	SetOntop(status)
+}
+
+// BitLabel is the header label of bit ix under the MSB/LSB numbering.
+func (m *MainForm) BitLabel(ix int) int {
+	if m.MLSwitchButton.Label() == "LSB" {
+		return dataWidth - 1 - ix
+	}
+	return ix
+}
+
+func (m *MainForm) UpdateStats() {
+	for r := 0; r < Row; r++ {
+		bitRow := m.BitRows[r]
+		if bitRow.stats == nil {
+			continue
+		}
+		st := bitRow.reg.Stats()
+		high, low := "-", "-"
+		if st.Ones > 0 {
+			high, low = fmt.Sprint(m.BitLabel(st.Highest)), fmt.Sprint(m.BitLabel(st.Lowest))
+		}
+		parity := "偶"
+		if st.OddParity() {
+			parity = "奇"
+		}
+		text := fmt.Sprintf("置位%d 前0:%d 后0:%d 高%s 低%s %s", st.Ones, st.LeadingZeros, st.TrailingZeros, high, low, parity)
+		if st.PowerOfTwo {
+			text += " 2的幂"
+		}
+		bitRow.stats.SetValue(text)
+		bitRow.stats.SetTooltip(fmt.Sprintf("置位数 %d\n前导0 %d, 末尾0 %d\n最高置位 %s, 最低置位 %s\n1的个数为%s数\n2的幂: %t", st.Ones, st.LeadingZeros, st.TrailingZeros, high, low, parity, st.PowerOfTwo))
+	}
+}
+
+func (m *MainForm) ShowStats() {
+	SetStats(m.StatsButton.Value())
+	m.Rebuild()
+}
+
+func (m *MainForm) MLSwitch() {
+	t := m.MLSwitchButton.Label()
+	m.MLSwitchButton.SetLabel(MLmap[t])
+	m.UpdateHeaderLabels()
+	m.UpdateAnalyzeArea()
+}
+
+func (m *MainForm) UpdateHeaderLabels() {
+	lsb := m.MLSwitchButton.Label() == "LSB"
+	for c := 0; c < dataWidth; c++ {
+		var label string
+		if lsb {
+			label = fmt.Sprint(c)
+		} else {
+			label = fmt.Sprint(dataWidth - 1 - c)
+		}
+		m.Headers[c].SetLabel(label)
+	}
+}
+
+func (m *MainForm) WidthChange() {
+	m.SetDataWidth(int(m.WidthSpin.Value()))
+}
+
+func (m *MainForm) SetDataWidth(width int) {
+	if width < 1 || width > maxDataWidth || width == dataWidth {
+		m.WidthSpin.SetValue(float64(dataWidth))
+		return
+	}
+	for _, bitRow := range m.BitRows {
+		reg := bitRow.reg
+		if reg.Width() == dataWidth || reg.Width() > width {
+			reg.SetWidth(width)
+		}
+	}
+	SetDataWidth(width)
+	m.Rebuild()
+}
+
+func (m *MainForm) SetLayout(layout *regmodel.Layout) {
+	for _, bitRow := range m.BitRows {
+		bitRow.reg.SetLayout(layout)
+	}
+	m.action = "位域"
+	m.Rebuild()
+}
+
+func (m *MainForm) EditLayout() {
+	if m.LayoutEditor == nil {
+		m.LayoutEditor = NewLayoutEditor(m)
+	}
+	if layout := m.BitRows[0].reg.Layout(); layout != nil {
+		m.LayoutEditor.buffer.SetText(layout.String())
+	}
+	m.LayoutEditor.window.Show()
+}
+
+func (m *MainForm) ShowFieldTable() {
+	if m.FieldTable == nil {
+		m.FieldTable = NewFieldTable()
+	}
+	m.FieldTable.window.Show()
+	m.UpdateFieldTable()
+}
+
+func (m *MainForm) UpdateFieldTable() {
+	table := m.FieldTable
+	if table == nil || !table.window.Visible() {
+		return
+	}
+	table.browser.Clear()
+	for r := 0; r < Row; r++ {
+		reg := m.BitRows[r].reg
+		layout := reg.Layout()
+		if layout == nil {
+			continue
+		}
+		table.browser.Add(fmt.Sprintf("@b@.%s %s", m.RowName(r), layout.Name))
+		if note := m.BitRows[r].note; note != "" {
+			table.browser.Add("@i@." + strings.ReplaceAll(note, "\n", " "))
+		}
+		for i := range layout.Fields {
+			f := &layout.Fields[i]
+			table.browser.Add(fmt.Sprintf("%s\t[%s]\t%s\t%s\t%s", f.Name, f.Range(), FieldText(reg, f, m.base), f.AccessType(), f.Description))
+		}
+	}
+}
+
+func (m *MainForm) ShowBases(reg *regmodel.Register) func() {
+	return func() {
+		view, ok := m.BaseViews[reg]
+		if !ok {
+			view = NewBaseView(reg)
+			m.BaseViews[reg] = view
+		}
+		view.window.Show()
+		m.UpdateBaseViews()
+	}
+}
+
+// UpdateBaseViews refreshes the open all-bases windows and drops those of
+// removed rows.
+func (m *MainForm) UpdateBaseViews() {
+	for reg, view := range m.BaseViews {
+		r := m.RowOf(reg)
+		if r < 0 {
+			view.window.Hide()
+			delete(m.BaseViews, reg)
+			continue
+		}
+		if view.window.Visible() {
+			view.window.SetLabel(m.RowName(r) + " 所有进制")
+			view.Update()
+		}
+	}
+}
+
+func (m *MainForm) ShowTimeline(reg *regmodel.Register) func() {
+	return func() {
+		view, ok := m.TimelineViews[reg]
+		if !ok {
+			view = NewTimelineView(m, reg)
+			m.TimelineViews[reg] = view
+		}
+		view.window.Show()
+		m.UpdateTimelines()
+	}
+}
+
+// UpdateTimelines logs the value of every row under the action that set it
+// and refreshes the open value logs. The logs of removed rows are dropped.
+func (m *MainForm) UpdateTimelines() {
+	if m.Timelines == nil {
+		return
+	}
+	for reg := range m.Timelines {
+		if m.RowOf(reg) < 0 {
+			delete(m.Timelines, reg)
+		}
+	}
+	for reg, view := range m.TimelineViews {
+		if m.RowOf(reg) < 0 {
+			view.window.Hide()
+			delete(m.TimelineViews, reg)
+		}
+	}
+	for r, bitRow := range m.BitRows {
+		timeline, ok := m.Timelines[bitRow.reg]
+		if !ok {
+			timeline = &regmodel.Timeline{Limit: 1000}
+			m.Timelines[bitRow.reg] = timeline
+		}
+		action := bitRow.action
+		if action == "" {
+			action = m.action
+		}
+		timeline.Add(bitRow.reg.Value(), action, action == "输入")
+		if view := m.TimelineViews[bitRow.reg]; view != nil && view.window.Visible() {
+			view.window.SetLabel(m.RowName(r) + " 值记录")
+			view.Update(timeline, m.base)
+		}
+	}
+}
+
+// RestoreValue loads entry i of the value log of reg back into its row.
+func (m *MainForm) RestoreValue(reg *regmodel.Register, i int) {
+	r := m.RowOf(reg)
+	timeline, ok := m.Timelines[reg]
+	if r < 0 || !ok {
+		return
+	}
+	records := timeline.Records()
+	if i >= len(records) {
+		return
+	}
+	bitRow := m.BitRows[r]
+	reg.SetValue(records[i].Value)
+	bitRow.action = "恢复" + records[i].Time.Format("15:04:05")
+	bitRow.UpdateBitNum()
+	m.Updateheaders()
+	m.UpdateAnalyzeArea()
+	bitRow.Display()
+}
+
+// RowOf is the visible row showing reg, -1 if there is none.
+func (m *MainForm) RowOf(reg *regmodel.Register) int {
+	for r := 0; r < Row; r++ {
+		if m.BitRows[r].reg == reg {
+			return r
+		}
+	}
+	return -1
+}
+
+// FixedRow is the row the fixed point dialog works on, -1 once it is gone.
+func (m *MainForm) FixedRow() int {
+	if m.FixedDialog == nil {
+		return -1
+	}
+	return m.RowOf(m.FixedDialog.reg)
+}
+
+// SelectedRange is the bit range or field of row r named in the analyze
+// input, as most and least significant bit.
+func (m *MainForm) SelectedRange(r int) (int, int, error) {
+	if f := m.AnalyzeField(r); f != nil {
+		return f.Msb, f.Lsb, nil
+	}
+	name, _ := m.AnalyzeInput()
+	nums := strings.Split(name, ":")
+	if len(nums) > 2 {
+		return 0, 0, fmt.Errorf("无效输入")
+	}
+	hi, err := m.BitIndex(nums[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	lo := hi
+	if len(nums) == 2 {
+		if lo, err = m.BitIndex(nums[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return hi, lo, nil
+}
+
+// ShiftRange applies a shift or rotate of the row showing reg to the bits
+// selected in the analyze input only.
+func (m *MainForm) ShiftRange(reg *regmodel.Register, name string, op func(*regmodel.Register, uint)) func() {
+	return func() {
+		r := m.RowOf(reg)
+		if r < 0 {
+			return
+		}
+		bitRow := m.BitRows[r]
+		hi, lo, err := m.SelectedRange(r)
+		if err != nil {
+			bitRow.ShowError(fmt.Errorf("位域解析中没有有效的位范围或位域名"))
+			return
+		}
+		n := uint(bitRow.GetCurrentNum())
+		reg.Within(hi, lo, func(sub *regmodel.Register) {
+			op(sub, n)
+		})
+		bitRow.action = fmt.Sprintf("%d:%d %s%d", hi, lo, name, n)
+		bitRow.UpdateBitNum()
+		m.Updateheaders()
+		m.UpdateAnalyzeArea()
+		bitRow.Display()
+	}
 }
 
-type BitAnalyze struct {
-	group *fltk.Group
-	res   []*fltk.Output
-	input *fltk.Input
+func (m *MainForm) ShowFixed(reg *regmodel.Register) func() {
+	return func() {
+		if m.FixedDialog == nil {
+			m.FixedDialog = NewFixedDialog(m)
+		}
+		dialog := m.FixedDialog
+		dialog.reg = reg
+		if r := m.FixedRow(); r >= 0 {
+			q, ok := m.BitRows[r].Fixed()
+			if !ok {
+				q = regmodel.QFormat{Signed: true, Int: 1, Frac: reg.Width() - 1}
+			}
+			dialog.format.SetValue(q.String())
+		}
+		dialog.result.SetLabel("")
+		dialog.window.Show()
+		m.UpdateFixedDialog()
+	}
 }
 
-func NewBitAnalyze() *BitAnalyze {
-	width := (WIDTH-pad*2)/(maxRow+1) - pad
-	bitAnalyze := new(BitAnalyze)
-	group := fltk.NewGroup(0, HEIGHT, WIDTH, bitH+12)
-	res := make([]*fltk.Output, maxRow)
-	for c := 0; c <= maxRow; c++ {
-		if c == 0 {
-			input := NewInput(c*(width+pad)+pad*2, HEIGHT, width, bitH, "")
-			input.SetLabel("Bit位域")
-			input.SetAlign(fltk.ALIGN_TOP)
-			input.SetLabelSize(12)
-			bitAnalyze.input = input
-		} else {
-			output := NewOutput(c*(width+pad)+pad*2, HEIGHT, width, bitH, fmt.Sprintf("第%d行", c))
-			res[c-1] = output
-			if c > Row {
-				output.Hide()
+// ApplyFixed shows the dialog's row in the typed fixed point format.
+func (m *MainForm) ApplyFixed() {
+	dialog := m.FixedDialog
+	r := m.FixedRow()
+	if r < 0 {
+		return
+	}
+	bitRow := m.BitRows[r]
+	q, err := regmodel.ParseQFormat(dialog.format.Value(), bitRow.reg.Width())
+	if err == nil && q.Width() > bitRow.reg.Width() {
+		err = fmt.Errorf("%s 共%d位, 超出行宽%d", q, q.Width(), bitRow.reg.Width())
+	}
+	if err != nil {
+		dialog.result.SetLabel(err.Error())
+		dialog.result.SetLabelColor(fltk.RED)
+		dialog.result.Redraw()
+		return
+	}
+	dialog.result.SetLabel("")
+	bitRow.q = q
+	bitRow.SetShow(showFixed, m.Updateheaders, m.UpdateAnalyzeArea)()
+}
+
+// WriteFixed quantizes the dialog's real number into its row.
+func (m *MainForm) WriteFixed() {
+	dialog := m.FixedDialog
+	r := m.FixedRow()
+	if r < 0 {
+		return
+	}
+	bitRow := m.BitRows[r]
+	if _, ok := bitRow.Fixed(); !ok {
+		m.ApplyFixed()
+	}
+	q, ok := bitRow.Fixed()
+	if !ok {
+		return
+	}
+	text := dialog.real.Value()
+	x, err := regmodel.ParseReal(text)
+	if err != nil {
+		dialog.result.SetLabel(err.Error())
+		dialog.result.SetLabelColor(fltk.RED)
+		dialog.result.Redraw()
+		return
+	}
+	bits, _ := q.Quantize(x)
+	bitRow.reg.SetValue(bits)
+	bitRow.UpdateBitNum()
+	dialog.result.SetLabel(fmt.Sprintf("写入 %s\n%s", q.Text(bits), bitRow.QuantizeNote(text)))
+	dialog.result.SetLabelColor(fltk.BLACK)
+	dialog.result.Redraw()
+	m.Updateheaders()
+	m.UpdateAnalyzeArea()
+}
+
+func (m *MainForm) UpdateFixedDialog() {
+	dialog := m.FixedDialog
+	if dialog == nil || !dialog.window.Visible() {
+		return
+	}
+	r := m.FixedRow()
+	if r < 0 {
+		dialog.window.Hide()
+		return
+	}
+	dialog.window.SetLabel(m.RowName(r) + " 定点格式")
+	bitRow := m.BitRows[r]
+	info := ""
+	if q, ok := bitRow.Fixed(); ok {
+		info = fmt.Sprintf("值 %s\n%s", q.Text(bitRow.reg.Value()), bitRow.Tip())
+	}
+	dialog.info.SetLabel(info)
+	dialog.info.Redraw()
+}
+
+func (m *MainForm) ShowRowOp() {
+	if m.RowOpDialog == nil {
+		m.RowOpDialog = NewRowOpDialog(m)
+		m.UpdateRowChoices()
+		m.RowOpDialog.b.SetValue(Row - 1)
+		m.RowOpDialog.target.SetValue(Row)
+	}
+	m.RowOpDialog.window.Show()
+}
+
+// ApplyRowOp writes A op B into the target row, which may be a new row. The
+// result is cut to the target row's width.
+func (m *MainForm) ApplyRowOp() {
+	dialog := m.RowOpDialog
+	a, b, target := dialog.a.Value(), dialog.b.Value(), dialog.target.Value()
+	op := regmodel.RowOps[dialog.op.Value()]
+	for Row <= target {
+		m.Add()
+	}
+	res := op.Apply(new(big.Int), m.BitRows[a].reg.Value(), m.BitRows[b].reg.Value())
+	bitRow := m.BitRows[target]
+	bitRow.reg.SetValue(res)
+	bitRow.action = op.Name
+	bitRow.UpdateBitNum()
+	m.Updateheaders()
+	m.UpdateAnalyzeArea()
+	text := fmt.Sprintf("%s = %s\nA = %s, B = %s", m.RowName(target), op.Name, m.RowName(a), m.RowName(b))
+	if width := bitRow.reg.Width(); res.BitLen() > width {
+		text += fmt.Sprintf(", 结果截取到%d位", width)
+	}
+	dialog.result.SetLabel(text)
+	dialog.result.Redraw()
+}
+
+func (m *MainForm) ShowDiffRow() {
+	SetDiffRow(m.RowOpDialog.diff.Value())
+	m.Rebuild()
+}
+
+func (m *MainForm) UpdateDiffRow() {
+	if m.DiffRow == nil {
+		return
+	}
+	width := m.BitRows[0].reg.Width()
+	if Row > 1 && m.BitRows[1].reg.Width() > width {
+		width = m.BitRows[1].reg.Width()
+	}
+	m.DiffRow.Update(m.Compare.Xor(), width, m.base)
+}
+
+func (m *MainForm) ShowMemory() {
+	if !m.MemoryButton.Value() {
+		if m.MemoryView != nil {
+			m.MemoryView.window.Hide()
+		}
+		return
+	}
+	if m.MemoryView == nil {
+		m.MemoryView = NewMemoryView(m)
+	}
+	m.MemoryView.window.Show()
+	m.UpdateMemoryView()
+}
+
+func (m *MainForm) UpdateMemoryView() {
+	view := m.MemoryView
+	if view == nil || !view.window.Visible() {
+		return
+	}
+	view.browser.Clear()
+	for r := 0; r < Row; r++ {
+		reg := m.BitRows[r].reg
+		for _, bigEndian := range []bool{false, true} {
+			var text strings.Builder
+			for i, b := range reg.Bytes(bigEndian) {
+				if i > 0 {
+					text.WriteByte(' ')
+				}
+				fmt.Fprintf(&text, "%02X", b)
+			}
+			order := "小端"
+			if bigEndian {
+				order = "大端"
 			}
+			view.browser.Add(fmt.Sprintf("@.%s\t%s\t%s", m.RowName(r), order, text.String()))
 		}
 	}
-	bitAnalyze.group = group
-	bitAnalyze.res = res
-	group.End()
-	group.Hide()
-	return bitAnalyze
 }
 
-type MainForm struct {
-	Group          *fltk.Group
-	Headers        Headers
-	BitRows        []*BitRow
-	AddRow         *fltk.Button
-	RmRow          *fltk.Button
-	Base16         *fltk.RadioRoundButton
-	Base10         *fltk.RadioRoundButton
-	Base8          *fltk.RadioRoundButton
-	ontop          *fltk.ToggleButton
-	base           int
-	MLSwitchButton *fltk.ToggleButton
-	BitColorSel    *fltk.Button
-	BitColorBox    *fltk.Box
-	HeaderColorSel *fltk.Button
-	HeaderColorBox *fltk.Box
-	BitRangeParse  *fltk.ToggleButton
-	AnalyzeArea    *BitAnalyze
-	ColorSelArea   *ColorSelect
+func (m *MainForm) ShowRegisters() {
+	if m.RegBrowser == nil {
+		m.RegBrowser = NewRegisterBrowser(m)
+		m.UpdateRowChoices()
+	}
+	m.RegBrowser.window.Show()
 }
 
-func (m *MainForm) Updateheaders() {
-	for c := 0; c < dataWidth; c++ {
-		bitMap := make(map[string]int, Row)
-		for r := 0; r < Row; r++ {
-			val := m.BitRows[r].bitLocs[c].Label()
-			bitMap[val] = 0
+// ImportMap asks for a description file and lists what load could read from
+// it in the register browser.
+func (m *MainForm) ImportMap(title, filter string, load func(io.Reader) (*regmodel.RegisterMap, error)) {
+	chooser := fltk.NewNativeFileChooser()
+	defer chooser.Destroy()
+	chooser.SetType(fltk.NativeFileChooser_BROWSE_FILE)
+	chooser.SetTitle(title)
+	chooser.SetFilter(filter)
+	chooser.Show()
+	files := chooser.Filenames()
+	if len(files) == 0 {
+		return
+	}
+	m.ShowRegisters()
+	file, err := os.Open(files[0])
+	if err != nil {
+		m.RegBrowser.SetMap(nil, err)
+		return
+	}
+	defer file.Close()
+	m.RegBrowser.SetMap(load(file))
+}
+
+// CurrentMap is the loaded register map, or one collected from the layouts of
+// the visible rows when nothing was imported.
+func (m *MainForm) CurrentMap() *regmodel.RegisterMap {
+	if m.RegBrowser != nil && m.RegBrowser.regMap != nil {
+		return m.RegBrowser.regMap
+	}
+	bank := &regmodel.Bank{Name: "rows"}
+	for r := 0; r < Row; r++ {
+		layout := m.BitRows[r].reg.Layout()
+		if layout == nil || bank.Register(layout.Name) != nil {
+			continue
 		}
-		if len(bitMap) == 1 {
-			m.Headers.UpdateHeader(c, 11)
-		} else {
-			m.Headers.UpdateHeader(c, 14)
+		if layout.Name == "" {
+			named := *layout
+			named.Name = fmt.Sprintf("row%d", r+1)
+			layout = &named
 		}
+		bank.Registers = append(bank.Registers, layout)
 	}
+	return &regmodel.RegisterMap{Banks: []*regmodel.Bank{bank}}
 }
 
-func (m *MainForm) AnalyzeAreaChange() {
-	if m.BitRangeParse.Value() {
-		m.AnalyzeArea.group.SetPosition(0, HEIGHT-bitH-pad)
-		m.AnalyzeArea.input.SetPosition(m.AnalyzeArea.input.X(), HEIGHT-bitH-pad)
-		for idx, output := range m.AnalyzeArea.res {
-			output.SetPosition(output.X(), HEIGHT-bitH-pad)
-			if idx+1 <= Row {
-				output.Show()
-			} else {
-				output.Hide()
-			}
+// SaveMap writes the current map after checking it; a map that fails
+// Validate is not saved and its problems are listed in the register browser.
+func (m *MainForm) SaveMap() {
+	regMap := m.CurrentMap()
+	if err := regMap.Validate(); err != nil {
+		m.ShowRegisters()
+		errs := regmodel.ErrorList{fmt.Errorf("寄存器表有错误, 未保存")}
+		if list, ok := err.(regmodel.ErrorList); ok {
+			errs = append(errs, list...)
 		}
+		m.RegBrowser.SetMap(nil, errs)
+		return
+	}
+	chooser := fltk.NewNativeFileChooser()
+	defer chooser.Destroy()
+	chooser.SetType(fltk.NativeFileChooser_BROWSE_SAVE_FILE)
+	chooser.SetTitle("保存寄存器表")
+	chooser.SetFilter("JSON\t*.json\nYAML\t*.{yaml,yml}")
+	chooser.Show()
+	files := chooser.Filenames()
+	if len(files) == 0 {
+		return
+	}
+	name := files[0]
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		name += ".json"
+	}
+	m.ShowRegisters()
+	file, err := os.Create(name)
+	if err != nil {
+		m.RegBrowser.SetMap(nil, err)
+		return
+	}
+	defer file.Close()
+	if ext == ".yaml" || ext == ".yml" {
+		err = regMap.WriteYAML(file)
+	} else {
+		err = regMap.WriteJSON(file)
 	}
+	m.RegBrowser.SetMap(regMap, err)
 }
 
-func (m *MainForm) Add() {
-	Row++
-	m.RmRow.Activate()
-	if Row == maxRow {
-		m.AddRow.Deactivate()
+// ApplyRegister loads a register description into row r: the row takes its
+// width, fields and reset value, widening the form if needed.
+func (m *MainForm) ApplyRegister(r int, layout *regmodel.Layout) {
+	for Row <= r {
+		m.Add()
 	}
-	HEIGHT += bitH + pad
-	m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
-	m.AnalyzeAreaChange()
-	bitRow := m.BitRows[Row-1]
-	bitRow.Show()
-	m.Updateheaders()
+	if layout.Width > dataWidth {
+		m.SetDataWidth(layout.Width)
+	}
+	reg := m.BitRows[r].reg
+	reg.SetWidth(layout.Width)
+	reg.SetLayout(layout)
+	reg.SetReset(layout.Reset)
+	reg.LoadReset()
+	m.action = m.RowName(r) + " 应用" + layout.Name
+	m.Rebuild()
 }
 
-func (m *MainForm) Remove() {
-	Row--
-	m.AddRow.Activate()
-	if Row == 1 {
-		m.RmRow.Deactivate()
+// Session collects the rows and display settings for saving.
+func (m *MainForm) Session() *regmodel.Session {
+	s := &regmodel.Session{
+		Width:     dataWidth,
+		Base:      m.base,
+		LSB:       m.MLSwitchButton.Label() == "LSB",
+		BitColor:  uint32(bitColorMap["1"]),
+		DiffColor: uint32(headerColorMap[14]),
 	}
-	HEIGHT -= bitH + pad
-	m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
-	m.AnalyzeAreaChange()
-	bitRow := m.BitRows[Row]
-	bitRow.ClickClear(nil, m.UpdateAnalyzeArea)()
-	bitRow.Hide()
-	m.Updateheaders()
+	if m.BitRangeParse.Value() {
+		s.Analyze = m.AnalyzeArea.input.Value()
+	}
+	for _, bitRow := range m.BitRows {
+		s.Rows = append(s.Rows, regmodel.SessionRow{
+			Register: bitRow.reg,
+			Label:    bitRow.name.Value(),
+			Note:     bitRow.note,
+			Tag:      bitRow.tag,
+			Show:     bitRow.show,
+			Fixed:    bitRow.q,
+		})
+	}
+	if m.RegBrowser != nil {
+		s.Map = m.RegBrowser.regMap
+	}
+	return s
 }
 
-func (m *MainForm) BaseChoise(base int) func() {
-	return func() {
-		m.base = base
-		for r := 0; r < maxRow; r++ {
-			m.BitRows[r].base = base
-			m.BitRows[r].UpdateNum()
-			m.UpdateAnalyzeRes(r)
-			if r < Row {
-				m.BitRows[r].Display()
-			}
-		}
+func (m *MainForm) SaveSession() {
+	chooser := fltk.NewNativeFileChooser()
+	defer chooser.Destroy()
+	chooser.SetType(fltk.NativeFileChooser_BROWSE_SAVE_FILE)
+	chooser.SetTitle("保存会话")
+	chooser.SetFilter("会话\t*.json")
+	chooser.Show()
+	files := chooser.Filenames()
+	if len(files) == 0 {
+		return
+	}
+	name := files[0]
+	if filepath.Ext(name) == "" {
+		name += ".json"
+	}
+	file, err := os.Create(name)
+	if err == nil {
+		err = m.Session().WriteJSON(file)
+		file.Close()
+	}
+	if err != nil {
+		fltk.MessageBox("保存会话", err.Error())
 	}
 }
 
-func (m *MainForm) SetOnTop() {
-	status := m.ontop.Value()
-	SetOntop(status)
+func (m *MainForm) OpenSession() {
+	chooser := fltk.NewNativeFileChooser()
+	defer chooser.Destroy()
+	chooser.SetType(fltk.NativeFileChooser_BROWSE_FILE)
+	chooser.SetTitle("打开会话")
+	chooser.SetFilter("会话\t*.json")
+	chooser.Show()
+	files := chooser.Filenames()
+	if len(files) == 0 {
+		return
+	}
+	file, err := os.Open(files[0])
+	if err != nil {
+		fltk.MessageBox("打开会话", err.Error())
+		return
+	}
+	defer file.Close()
+	s, err := regmodel.LoadSession(file)
+	if s != nil {
+		m.ApplySession(s)
+	}
+	if err != nil {
+		fltk.MessageBox("打开会话", err.Error())
+	}
 }
 
-func (m *MainForm) MLSwitch() {
-	t := m.MLSwitchButton.Label()
-	for c := 0; c < dataWidth; c++ {
-		var label string
-		if t == "LSB" {
-			label = fmt.Sprint(dataWidth - 1 - c)
-		} else {
-			label = fmt.Sprint(c)
+// ApplySession replaces all rows and display settings with those of s. Rows
+// wider than the form widen it.
+func (m *MainForm) ApplySession(s *regmodel.Session) {
+	width := s.Width
+	for _, row := range s.Rows {
+		if row.Register.Width() > width {
+			width = row.Register.Width()
 		}
-		m.Headers[c].SetLabel(label)
 	}
-	m.MLSwitchButton.SetLabel(MLmap[t])
+	if width > maxDataWidth {
+		width = maxDataWidth
+	}
+	states := make([]rowState, len(s.Rows))
+	for r, row := range s.Rows {
+		if row.Register.Width() > width {
+			row.Register.SetWidth(width)
+		}
+		states[r] = rowState{row.Register, row.Show, row.Fixed, row.Label, row.Note, row.Tag}
+	}
+	SetDataWidth(width)
+	m.base = s.Base
+	if s.LSB {
+		m.MLSwitchButton.SetLabel("LSB")
+	} else {
+		m.MLSwitchButton.SetLabel("MSB")
+	}
+	m.BitRangeParse.SetValue(s.Analyze != "")
+	m.AnalyzeArea.input.SetValue(s.Analyze)
+	if s.BitColor != 0 {
+		bitColorMap["1"] = fltk.Color(s.BitColor)
+	}
+	if s.DiffColor != 0 {
+		headerColorMap[14] = fltk.Color(s.DiffColor)
+	}
+	if s.Map != nil {
+		if m.RegBrowser == nil {
+			m.RegBrowser = NewRegisterBrowser(m)
+		}
+		m.RegBrowser.SetMap(s.Map, nil)
+	}
+	m.action = "打开会话"
+	m.RebuildRows(states)
+}
+
+// rowState is what a row keeps when the form is rebuilt.
+type rowState struct {
+	reg   *regmodel.Register
+	show  int
+	q     regmodel.QFormat
+	label string
+	note  string
+	tag   int
+}
+
+func (m *MainForm) RowStates() []rowState {
+	states := make([]rowState, len(m.BitRows))
+	for r, bitRow := range m.BitRows {
+		states[r] = rowState{bitRow.reg, bitRow.show, bitRow.q, bitRow.name.Value(), bitRow.note, bitRow.tag}
+	}
+	return states
+}
+
+// Rebuild recreates the form for the current layout globals while keeping the
+// row values and toggles. Widgets whose callbacks call Rebuild must live
+// outside m.Form.
+func (m *MainForm) Rebuild() {
+	m.RebuildRows(m.RowStates())
+}
+
+// RebuildRows is Rebuild with the given rows, used to add, remove and
+// reorder them.
+func (m *MainForm) RebuildRows(states []rowState) {
+	layout := states[0].reg.Layout()
+	SetFieldStrip(layout != nil && len(layout.Fields) > 0)
+	ml := m.MLSwitchButton.Label()
+	ontop := m.ontop.Value()
+	simulate := m.SimulateButton.Value()
+	resetDiff := m.ResetDiffButton.Value()
+	memory := m.MemoryButton.Value()
+	analyze := m.BitRangeParse.Value()
+	expr := m.AnalyzeArea.input.Value()
+	scroll := m.Rows.YPosition()
+	SetRows(len(states), analyze)
+	m.Form.Destroy()
+	m.Window.Begin()
+	m.initComponents(states)
+	m.Window.End()
+	m.MLSwitchButton.SetLabel(ml)
+	m.UpdateHeaderLabels()
+	m.ontop.SetValue(ontop)
+	m.SimulateButton.SetValue(simulate)
+	m.Simulate()
+	m.ResetDiffButton.SetValue(resetDiff)
+	m.MemoryButton.SetValue(memory)
+	m.ShowResetDiff()
+	m.BitRangeParse.SetValue(analyze)
+	m.AnalyzeArea.input.SetValue(expr)
+	if analyze {
+		m.AnalyzeArea.group.Show()
+		m.AnalyzeAreaChange()
+	}
+	m.WidthSpin.SetValue(float64(dataWidth))
+	m.WidthSpin.Resize(WIDTH-325, 18, 46, 25)
+	m.WidthPreset.Resize(WIDTH-279, 18, 16, 25)
+	m.AddRow.Resize(WIDTH-66, pad-1, 60, 20)
+	m.RmRow.Resize(WIDTH-66, pad+21, 60, 20)
+	if Row == 1 {
+		m.RmRow.Deactivate()
+	} else {
+		m.RmRow.Activate()
+	}
+	if bottom := Row*(bitH+pad) - RowsH(); scroll > bottom {
+		scroll = bottom
+	}
+	m.Rows.ScrollTo(0, scroll)
+	m.Window.SetSizeRange(WIDTH, minHeight, WIDTH, maxHeight, 0, 0, false)
+	m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
+	m.Updateheaders()
 	m.UpdateAnalyzeArea()
+	m.UpdateRowChoices()
+	m.Window.Redraw()
 }
 
 func (m *MainForm) Analyze() {
 	boolean := m.BitRangeParse.Value()
+	SetRows(Row, boolean)
+	m.Window.SetSizeRange(WIDTH, minHeight, WIDTH, maxHeight, 0, 0, false)
+	m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
 	if boolean {
-		m.AnalyzeArea.group.SetPosition(0, HEIGHT)
-		m.AnalyzeArea.input.SetPosition(m.AnalyzeArea.input.X(), HEIGHT)
-		for _, output := range m.AnalyzeArea.res {
-			output.SetPosition(output.X(), HEIGHT)
-		}
-		HEIGHT += bitH + 14
 		m.AnalyzeArea.group.Show()
-		m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
 	} else {
 		m.AnalyzeArea.group.Hide()
-		HEIGHT -= bitH + 14
-		m.Group.Resize(m.Group.X(), m.Group.Y(), WIDTH, HEIGHT)
 	}
 	m.AnalyzeAreaChange()
 }
 
-func (m *MainForm) SetNum(num *big.Int, r int) {
-	m.AnalyzeArea.res[r].SetValue(num.Text(m.base))
+func (m *MainForm) BitIndex(label string) (int, error) {
+	num, err := strconv.ParseInt(strings.Trim(label, "\r\n"), 10, 0)
+	if err != nil || num < 0 || num >= int64(dataWidth) {
+		return 0, fmt.Errorf("无效输入")
+	}
+	if m.MLSwitchButton.Label() == "LSB" {
+		return dataWidth - 1 - int(num), nil
+	}
+	return int(num), nil
 }
 
-func (m *MainForm) ParseBitRange(nums []string, r int32) (*big.Int, error) {
-	var res []string
-	bigI := big.NewInt(0)
+// ParseBitRange extracts the bits named by nums from row r and returns them
+// with their count.
+func (m *MainForm) ParseBitRange(nums []string, r int32) (*big.Int, int, error) {
+	reg := m.BitRows[r].reg
 	if len(nums) == 1 {
-		left := strings.Trim(nums[0], "\r\n")
-		num, err := strconv.ParseInt(left, 10, 0)
-		if err != nil || num >= int64(dataWidth) {
-			return bigI, fmt.Errorf("无效输入")
-		}
-		for c := 0; c < dataWidth; c++ {
-			if m.Headers[c].Label() == left {
-				str := m.BitRows[r].bitLocs[c].Label()
-				res = append(res, str)
-			}
+		ix, err := m.BitIndex(nums[0])
+		if err != nil {
+			return big.NewInt(0), 0, err
 		}
-		bigI.SetString(strings.Join(res, ""), 2)
-		return bigI, nil
+		return reg.Extract(ix, ix), 1, nil
 	} else if len(nums) == 2 {
-		left := strings.Trim(nums[0], "\r\n")
-		right := strings.Trim(nums[1], "\r\n")
-		numL, errL := strconv.ParseInt(left, 10, 0)
-		if errL != nil || numL >= int64(dataWidth) {
-			return bigI, fmt.Errorf("无效输入")
-		}
-		numR, errR := strconv.ParseInt(right, 10, 0)
-		if errR != nil || numR >= int64(dataWidth) {
-			return bigI, fmt.Errorf("无效输入")
-		}
-		if numR == numL {
-			return bigI, fmt.Errorf("无效输入")
-		}
-		flag := false
-		for c := 0; c < dataWidth; c++ {
-			var str string
-			cap := m.Headers[c].Label()
-			if (cap == left && !flag) || (cap == right && !flag) {
-				flag = true
-			} else if (cap == right && flag) || (cap == left && flag) {
-				flag = false
-				str = m.BitRows[r].bitLocs[c].Label()
-				res = append(res, str)
-			}
-			if flag {
-				str = m.BitRows[r].bitLocs[c].Label()
-				res = append(res, str)
-			}
+		left, errL := m.BitIndex(nums[0])
+		if errL != nil {
+			return big.NewInt(0), 0, errL
+		}
+		right, errR := m.BitIndex(nums[1])
+		if errR != nil {
+			return big.NewInt(0), 0, errR
 		}
-		bigI.SetString(strings.Join(res, ""), 2)
-		return bigI, nil
+		if left == right {
+			return big.NewInt(0), 0, fmt.Errorf("无效输入")
+		}
+		width := left - right + 1
+		if left < right {
+			width = right - left + 1
+		}
+		return reg.Extract(left, right), width, nil
 	} else {
-		return bigI, fmt.Errorf("无效输入")
+		return big.NewInt(0), 0, fmt.Errorf("无效输入")
+	}
+}
+
+// AnalyzeInput splits the analyze input into the bit range or field name and
+// an optional format after a space: s reads the bits as a signed number,
+// Qm.n / UQm.n / Qn as fixed point.
+func (m *MainForm) AnalyzeInput() (string, string) {
+	text := strings.TrimSpace(m.AnalyzeArea.input.Value())
+	if ix := strings.LastIndexAny(text, " \t"); ix >= 0 {
+		format := strings.ToLower(text[ix+1:])
+		if format == "s" || format == "signed" {
+			return strings.TrimSpace(text[:ix]), "s"
+		}
+		if _, err := regmodel.ParseQFormat(format, maxDataWidth); err == nil {
+			return strings.TrimSpace(text[:ix]), format
+		}
+	}
+	return text, ""
+}
+
+// AnalyzeText formats bits read from a field or bit range in the analyze
+// format.
+func (m *MainForm) AnalyzeText(num *big.Int, width int, format string) string {
+	switch format {
+	case "":
+		return regmodel.FormatNumber(num, m.base)
+	case "s":
+		return regmodel.Signed(num, width).String()
+	}
+	q, err := regmodel.ParseQFormat(format, width)
+	if err != nil {
+		return err.Error()
+	}
+	if q.Width() != width {
+		return fmt.Sprintf("%s 共%d位, 位域%d位", q, q.Width(), width)
+	}
+	return q.Text(num)
+}
+
+// AnalyzeField is the field of row r named in the analyze input, if any.
+func (m *MainForm) AnalyzeField(r int) *regmodel.Field {
+	layout := m.BitRows[r].reg.Layout()
+	if layout == nil {
+		return nil
 	}
+	name, _ := m.AnalyzeInput()
+	return layout.Field(name)
+}
+
+// SetFieldValue writes an enum value picked from the decode area back into
+// row r.
+func (m *MainForm) SetFieldValue(r int, name string, value *big.Int) func() {
+	return func() {
+		bitRow := m.BitRows[r]
+		layout := bitRow.reg.Layout()
+		if layout == nil || layout.Field(name) == nil {
+			return
+		}
+		bitRow.reg.SetField(layout.Field(name), value)
+		bitRow.UpdateBitNum()
+		m.Updateheaders()
+		m.UpdateAnalyzeArea()
+	}
+}
+
+func (m *MainForm) UpdateEnums(r int, f *regmodel.Field) {
+	enum := m.AnalyzeArea.enums[r]
+	enum.Clear()
+	if f == nil || len(f.Enums) == 0 || r >= Row {
+		enum.Hide()
+		return
+	}
+	for _, e := range f.Enums {
+		enum.Add(fmt.Sprintf("%s (%s)", strings.ReplaceAll(e.Name, "/", "\\/"), regmodel.FormatNumber(e.Value, m.base)), m.SetFieldValue(r, f.Name, e.Value))
+	}
+	enum.Show()
 }
 
 func (m *MainForm) UpdateAnalyzeRes(r int) {
-	str := m.AnalyzeArea.input.Value()
-	num, err := m.ParseBitRange(strings.Split(str, ":"), int32(r))
 	output := m.AnalyzeArea.res[r]
+	output.SetLabel(m.RowName(r))
+	f := m.AnalyzeField(r)
+	m.UpdateEnums(r, f)
+	str, format := m.AnalyzeInput()
+	if f != nil {
+		reg := m.BitRows[r].reg
+		text := FieldText(reg, f, m.base)
+		if format != "" {
+			text = m.AnalyzeText(reg.FieldValue(f), f.Width(), format)
+		}
+		output.SetValue(fmt.Sprintf("%s = %s", f.Name, text))
+		output.SetTooltip(m.RowName(r) + ": " + output.Value())
+		if len(f.Enums) > 0 && f.Enum(reg.FieldValue(f)) == nil {
+			output.SetColor(fltk.RED)
+		} else {
+			output.SetColor(fltk.WHITE)
+		}
+		output.Redraw()
+		return
+	}
+	num, width, err := m.ParseBitRange(strings.Split(str, ":"), int32(r))
 	if err != nil {
 		if str != "" {
 			output.SetValue("无效输入")
@@ -769,15 +2823,16 @@ func (m *MainForm) UpdateAnalyzeRes(r int) {
 			output.SetColor(fltk.WHITE)
 		}
 	} else {
-		m.SetNum(num, r)
+		output.SetValue(m.AnalyzeText(num, width, format))
 		output.SetColor(fltk.WHITE)
 	}
+	output.SetTooltip(m.RowName(r) + ": " + output.Value())
 	output.Redraw()
 }
 
 func (m *MainForm) Edit(e fltk.Event) bool {
 	if e == fltk.KEYUP {
-		for r := 0; r < maxRow; r++ {
+		for r := 0; r < Row; r++ {
 			m.UpdateAnalyzeRes(r)
 		}
 		return true
@@ -787,50 +2842,319 @@ func (m *MainForm) Edit(e fltk.Event) bool {
 
 func (m *MainForm) UpdateAnalyzeArea() {
 	m.Edit(fltk.KEYUP)
+	m.UpdateFieldTable()
+	m.UpdateBaseViews()
+	m.UpdateFixedDialog()
+	m.UpdateMemoryView()
+	m.UpdateStats()
+	m.UpdateDiffRow()
+	m.UpdateTimelines()
+	m.Record()
+}
+
+// Record adds the current rows to the history if they changed since the
+// entry shown. The entry is named after the action of the form or the
+// changed row; consecutive typing in one row is merged into one entry.
+func (m *MainForm) Record() {
+	if m.History == nil {
+		return
+	}
+	state := m.Session()
+	cur := m.History.Current()
+	name, typing := m.action, false
+	m.action = ""
+	actions := make([]string, len(m.BitRows))
+	for r, bitRow := range m.BitRows {
+		actions[r] = bitRow.action
+		bitRow.action = ""
+	}
+	if cur != nil && cur.State.Equal(state) {
+		return
+	}
+	switch {
+	case name != "":
+	case cur == nil:
+		name = "初始"
+	case state.Width != cur.State.Width:
+		name = fmt.Sprintf("位宽%d", state.Width)
+	case len(state.Rows) > len(cur.State.Rows):
+		name = "增加一行"
+	case len(state.Rows) < len(cur.State.Rows):
+		name = "删除一行"
+	case state.Base != cur.State.Base:
+		name = fmt.Sprintf("%d进制", state.Base)
+	default:
+		changed := state.ChangedRows(cur.State)
+		if len(changed) != 1 {
+			name = fmt.Sprintf("修改%d行", len(changed))
+			break
+		}
+		r := changed[0]
+		action := actions[r]
+		if action == "" {
+			action = "修改"
+		}
+		name = m.RowName(r) + " " + action
+		typing = action == "输入"
+	}
+	if typing && cur.Name == name && !m.History.CanRedo() {
+		m.History.Replace(name, state.Clone())
+	} else {
+		m.History.Push(name, state.Clone())
+	}
+	m.UpdateHistory()
+}
+
+// Restore shows a state from the history: its rows, data width and base.
+// Rows that still exist keep their registers, so the windows following them
+// stay open. action names the step in the value logs.
+func (m *MainForm) Restore(s *regmodel.Session, action string) {
+	if s == nil {
+		return
+	}
+	m.action = action
+	states := make([]rowState, len(s.Rows))
+	for r, row := range s.Rows {
+		reg := row.Register.Clone()
+		if r < len(m.BitRows) {
+			reg = m.BitRows[r].reg
+			reg.Set(row.Register)
+		}
+		states[r] = rowState{reg, row.Show, row.Fixed, row.Label, row.Note, row.Tag}
+	}
+	SetDataWidth(s.Width)
+	m.base = s.Base
+	m.RebuildRows(states)
+	m.UpdateHistory()
+}
+
+func (m *MainForm) Undo() {
+	m.Restore(m.History.Undo(), "撤销")
+}
+
+func (m *MainForm) Redo() {
+	m.Restore(m.History.Redo(), "重做")
+}
+
+func (m *MainForm) ShowHistory() {
+	if m.HistoryView == nil {
+		m.HistoryView = NewHistoryView(m)
+	}
+	m.UpdateHistory()
+	m.HistoryView.window.Show()
+}
+
+func (m *MainForm) UpdateHistory() {
+	if m.HistoryView != nil {
+		m.HistoryView.Update(m.History)
+	}
+}
+
+// HistoryView lists the history, newest last; clicking an entry goes back
+// (or forward) to it. Entries that can be redone are shown in italics.
+type HistoryView struct {
+	window  *fltk.Window
+	browser *fltk.HoldBrowser
+}
+
+func NewHistoryView(m *MainForm) *HistoryView {
+	w, h := 320, 360
+	view := new(HistoryView)
+	win := fltk.NewWindow(w, h, "历史记录")
+	browser := fltk.NewHoldBrowser(0, 0, w, h-bitH-pad*4)
+	browser.SetColumnWidths(70, 0)
+	browser.SetCallback(func() {
+		if line := browser.Value(); line > 0 {
+			m.Restore(m.History.Jump(line-1), "历史记录")
+		}
+	})
+	NewButton(pad*2, h-bitH-pad*2, ButtonW*2, bitH, "撤销", m.Undo)
+	NewButton(pad*4+ButtonW*2, h-bitH-pad*2, ButtonW*2, bitH, "重做", m.Redo)
+	win.Resizable(browser)
+	win.End()
+	view.window = win
+	view.browser = browser
+	return view
+}
+
+func (v *HistoryView) Update(history *regmodel.History) {
+	v.browser.Clear()
+	for i, entry := range history.Entries() {
+		format := ""
+		if i > history.Pos() {
+			format = "@i"
+		}
+		v.browser.Add(fmt.Sprintf("%s%s\t%s@.%s", format, entry.Time.Format("15:04:05"), format, entry.Name))
+	}
+	v.browser.SetValue(history.Pos() + 1)
+	v.browser.SetBottomLine(history.Pos() + 1)
 }
 
-func NewMainForm(w *fltk.Window) {
+func NewMainForm(w *fltk.Window) *MainForm {
 	mainForm := new(MainForm)
 	mainForm.base = 16
-	bitRows := make([]*BitRow, maxRow)
-	for r := 0; r <= maxRow; r++ {
-		if r == 0 {
-			mainForm.Headers = NewHeaders()
-		} else {
-			bitRow := NewBitRow(r, mainForm.Updateheaders, mainForm.UpdateAnalyzeArea)
-			if r > Row {
-				bitRow.Hide()
-			}
-			bitRows[r-1] = bitRow
-		}
+	mainForm.BaseViews = make(map[*regmodel.Register]*BaseView)
+	mainForm.Timelines = make(map[*regmodel.Register]*regmodel.Timeline)
+	mainForm.TimelineViews = make(map[*regmodel.Register]*TimelineView)
+	widthSpin := fltk.NewSpinner(WIDTH-325, 18, 46, 25)
+	widthSpin.SetType(fltk.SPINNER_INT_INPUT)
+	widthSpin.SetMinimum(1)
+	widthSpin.SetMaximum(float64(maxDataWidth))
+	widthSpin.SetStep(8)
+	widthSpin.SetValue(float64(dataWidth))
+	widthSpin.SetLabel("位宽")
+	widthSpin.SetLabelSize(12)
+	widthSpin.SetAlign(fltk.ALIGN_TOP)
+	widthSpin.SetCallback(mainForm.WidthChange)
+	widthPreset := fltk.NewMenuButton(WIDTH-279, 18, 16, 25)
+	for _, width := range regmodel.Widths {
+		width := width
+		widthPreset.Add(fmt.Sprint(width), func() {
+			mainForm.SetDataWidth(width)
+		})
 	}
-	mainForm.BitRows = bitRows
-	box := NewBox(fltk.GTK_UP_BOX, WIDTH-189, 18, 118, 25, 12, "进制", fltk.WHITE)
-	box.SetAlign(fltk.ALIGN_TOP)
-	base16 := NewRadioRoundButton(WIDTH-184, pad*11+1, 16, 16, 16, "16", mainForm.BaseChoise)
-	mainForm.Base16 = base16
-	base10 := NewRadioRoundButton(WIDTH-144, pad*11+1, 16, 16, 10, "10", mainForm.BaseChoise)
-	mainForm.Base10 = base10
-	base8 := NewRadioRoundButton(WIDTH-104, pad*11+1, 16, 16, 8, "8", mainForm.BaseChoise)
-	mainForm.Base8 = base8
+	stats := NewToggleButton(pad*12+530, pad*4, 40, 20, "统计")
+	stats.SetTooltip("每行末尾显示置位数, 前导/末尾0, 最高/最低置位, 奇偶和是否2的幂")
+	stats.SetCallback(mainForm.ShowStats)
+	mainForm.StatsButton = stats
+	session := NewMenuButton(pad*14+620, pad*4, 50, 20, "会话")
+	session.Add("保存会话...", mainForm.SaveSession)
+	session.Add("打开会话...", mainForm.OpenSession)
+	history := NewMenuButton(pad*15+670, pad*4, 50, 20, "历史")
+	history.AddEx("撤销", fltk.CTRL+'z', mainForm.Undo, 0)
+	history.AddEx("重做", fltk.CTRL+'y', mainForm.Redo, 0)
+	history.Add("历史记录...", mainForm.ShowHistory)
+	layoutMenu := NewMenuButton(pad*8+310, pad*4, 60, 20, "寄存器")
+	layoutMenu.Add("导入SVD...", func() {
+		mainForm.ImportMap("导入SVD", "CMSIS-SVD\t*.svd", regmodel.LoadSVD)
+	})
+	layoutMenu.Add("导入IP-XACT...", func() {
+		mainForm.ImportMap("导入IP-XACT", "IP-XACT\t*.{xml,ipxact}", regmodel.LoadIPXACT)
+	})
+	layoutMenu.Add("导入SystemRDL...", func() {
+		mainForm.ImportMap("导入SystemRDL", "SystemRDL\t*.rdl", regmodel.LoadRDL)
+	})
+	layoutMenu.Add("导入C头文件...", func() {
+		mainForm.ImportMap("导入C头文件", "C头文件\t*.{h,hpp}", regmodel.LoadCHeader)
+	})
+	layoutMenu.Add("打开寄存器表...", func() {
+		mainForm.ImportMap("打开寄存器表", "寄存器表\t*.{json,yaml,yml}", regmodel.LoadMap)
+	})
+	layoutMenu.Add("保存寄存器表...", mainForm.SaveMap)
+	layoutMenu.Add("寄存器列表", mainForm.ShowRegisters)
+	layoutMenu.Add("定义位域...", mainForm.EditLayout)
+	layoutMenu.Add("位域表", mainForm.ShowFieldTable)
+	layoutMenu.Add("清除位域", func() {
+		mainForm.SetLayout(nil)
+	})
+	mainForm.WidthSpin = widthSpin
+	mainForm.WidthPreset = widthPreset
+	mainForm.LayoutMenu = layoutMenu
 	addR := NewButton(WIDTH-66, pad-1, 60, 20, "增加一行", mainForm.Add)
 	rmR := NewButton(WIDTH-66, pad+21, 60, 20, "删除一行", mainForm.Remove)
 	rmR.Deactivate()
 	mainForm.AddRow = addR
 	mainForm.RmRow = rmR
+	mainForm.initComponents([]rowState{{reg: regmodel.NewRegister(dataWidth)}})
+	mainForm.History = &regmodel.History{Limit: 500}
+	mainForm.UpdateTimelines()
+	mainForm.Record()
+	mainForm.Window = w
+	mainForm.Group = &w.Group
+	return mainForm
+}
+
+func (m *MainForm) initComponents(states []rowState) {
+	form := NewGroup(0, 0, WIDTH, maxHeight)
+	m.Headers = NewHeaders()
+	rows := fltk.NewScroll(0, ParseHeight(1), WIDTH, RowsH())
+	rows.SetType(fltk.VERTICAL)
+	rows.SetColor(fltk.WHITE)
+	bitRows := make([]*BitRow, len(states))
+	m.Compare = regmodel.NewComparison()
+	for r, state := range states {
+		bitRow := NewBitRow(r+1, state.reg, m.Updateheaders, m.UpdateAnalyzeArea)
+		bitRow.base = m.base
+		bitRow.lookup = m.RowValue
+		bitRow.show = state.show
+		bitRow.q = state.q
+		bitRow.name.SetValue(state.label)
+		bitRow.name.SetCallback(m.RowRenamed)
+		bitRow.note = state.note
+		bitRow.tag = state.tag
+		bitRow.UpdateName()
+		bitRow.UpdateBit()
+		bitRow.grip.SetEventHandler(m.DragRow(r))
+		bitRow.menu.Add("所有进制...", m.ShowBases(bitRow.reg))
+		bitRow.menu.Add("值记录...", m.ShowTimeline(bitRow.reg))
+		bitRow.menu.Add("定点格式...", m.ShowFixed(bitRow.reg))
+		bitRow.menu.Add("备注...", m.EditNote(bitRow.reg))
+		for _, shift := range shifts {
+			bitRow.menu.Add("移位/位域解析范围内"+shift.name, m.ShiftRange(bitRow.reg, shift.name, shift.op))
+		}
+		bitRow.SetNum()
+		m.Compare.Add(bitRow.reg)
+		bitRows[r] = bitRow
+	}
+	rows.End()
+	m.Rows = rows
+	m.BitRows = bitRows
+	m.DiffRow = nil
+	if diffH > 0 {
+		m.DiffRow = NewDiffRow(ParseHeight(1) + RowsH())
+	}
+	m.FieldStrip = NewFieldStrip(states[0].reg.Layout())
+	box := NewBox(fltk.GTK_UP_BOX, WIDTH-261, 18, 190, 25, 12, "进制", fltk.WHITE)
+	box.SetAlign(fltk.ALIGN_TOP)
+	base16 := NewRadioRoundButton(WIDTH-256, pad*11+1, 16, 16, 16, "16", m.BaseChoise)
+	m.Base16 = base16
+	base10 := NewRadioRoundButton(WIDTH-221, pad*11+1, 16, 16, 10, "10", m.BaseChoise)
+	m.Base10 = base10
+	base8 := NewRadioRoundButton(WIDTH-186, pad*11+1, 16, 16, 8, "8", m.BaseChoise)
+	m.Base8 = base8
+	base2 := NewRadioRoundButton(WIDTH-151, pad*11+1, 16, 16, 2, "2", m.BaseChoise)
+	base2.SetTooltip("二进制, 每4位用_分隔")
+	m.Base2 = base2
+	base36 := NewRadioRoundButton(WIDTH-116, pad*11+1, 16, 16, 36, "36", m.BaseChoise)
+	m.Base36 = base36
 	ontop := NewToggleButton(pad*3, pad*4, 35, 20, "置顶")
-	ontop.SetCallback(mainForm.SetOnTop)
-	base16.SetValue(true)
-	mainForm.ontop = ontop
+	ontop.SetCallback(m.SetOnTop)
+	switch m.base {
+	case 10:
+		base10.SetValue(true)
+	case 8:
+		base8.SetValue(true)
+	case 2:
+		base2.SetValue(true)
+	case 36:
+		base36.SetValue(true)
+	default:
+		base16.SetValue(true)
+	}
+	m.ontop = ontop
+	simulate := NewToggleButton(pad*9+370, pad*4, 50, 20, "模拟写")
+	simulate.SetTooltip("输入数值后按回车, 按位域访问类型(W1C/RO...)模拟写入")
+	simulate.SetCallback(m.Simulate)
+	m.SimulateButton = simulate
+	rowOp := NewButton(pad*13+570, pad*4, 50, 20, "行运算", m.ShowRowOp)
+	rowOp.SetTooltip("两行按位与/或/异或/A有B无, 结果写入指定行; 可在主窗口显示行1^行2差异行")
+	resetDiff := NewToggleButton(pad*10+420, pad*4, 60, 20, "复位对比")
+	resetDiff.SetTooltip("红色标出与复位值不同的位")
+	resetDiff.SetCallback(m.ShowResetDiff)
+	m.ResetDiffButton = resetDiff
+	memory := NewToggleButton(pad*11+480, pad*4, 50, 20, "内存序")
+	memory.SetTooltip("按内存地址顺序列出各行字节(小端/大端)")
+	memory.SetCallback(m.ShowMemory)
+	m.MemoryButton = memory
 	mlSwitch := NewToggleButton(pad*4+35, pad*4, 35, 20, "MSB")
-	mlSwitch.SetCallback(mainForm.MLSwitch)
+	mlSwitch.SetCallback(m.MLSwitch)
 	rangeParse := NewToggleButton(pad*5+70, pad*4, 60, 20, "位域解析")
 	analyzeArea := NewBitAnalyze()
-	analyzeArea.input.SetEventHandler(mainForm.Edit)
-	rangeParse.SetCallback(mainForm.Analyze)
+	analyzeArea.input.SetEventHandler(m.Edit)
+	rangeParse.SetCallback(m.Analyze)
 	bitColorBox := fltk.NewBox(fltk.BORDER_BOX, pad*9+130, pad*6, 12, 12)
 	bitColorBox.SetColor(bitColorMap["1"])
-	colorDia := NewColorSelect(mainForm)
+	colorDia := NewColorSelect(m)
 	callBack := func(i int) func() {
 		return func() {
 			if colorDia.group.Visible() {
@@ -846,15 +3170,16 @@ func NewMainForm(w *fltk.Window) {
 	headerColorBox := fltk.NewBox(fltk.BORDER_BOX, pad*10+210, pad*6, 12, 12)
 	headerColorBox.SetColor(headerColorMap[14])
 	headerColorSel := NewButton(pad*7+230, pad*4, 80, 20, "对比颜色选择", callBack(1))
-	mainForm.BitColorSel = bitColorSel
-	mainForm.BitColorBox = bitColorBox
-	mainForm.HeaderColorSel = headerColorSel
-	mainForm.HeaderColorBox = headerColorBox
-	mainForm.MLSwitchButton = mlSwitch
-	mainForm.BitRangeParse = rangeParse
-	mainForm.ColorSelArea = colorDia
-	mainForm.AnalyzeArea = analyzeArea
-	mainForm.Group = &w.Group
+	m.BitColorSel = bitColorSel
+	m.BitColorBox = bitColorBox
+	m.HeaderColorSel = headerColorSel
+	m.HeaderColorBox = headerColorBox
+	m.MLSwitchButton = mlSwitch
+	m.BitRangeParse = rangeParse
+	m.ColorSelArea = colorDia
+	m.AnalyzeArea = analyzeArea
+	form.End()
+	m.Form = form
 }
 
 func main() {
@@ -0,0 +1,121 @@
+package regmodel
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Access is what a write does to a field.
+type Access int
+
+const (
+	RW Access = iota
+	RO
+	WO
+	W1C
+	W1S
+	W1T
+	W0C
+	W0S
+	WC
+	WS
+	RC
+	RS
+	Reserved
+)
+
+var accessNames = []string{"RW", "RO", "WO", "W1C", "W1S", "W1T", "W0C", "W0S", "WC", "WS", "RC", "RS", "RSV"}
+
+func (a Access) String() string {
+	return accessNames[a]
+}
+
+// ParseAccess reads the short names RW, RO, W1C, ... as written by String.
+func ParseAccess(s string) (Access, bool) {
+	for i, name := range accessNames {
+		if strings.EqualFold(s, name) {
+			return Access(i), true
+		}
+	}
+	return RW, false
+}
+
+var writeActions = map[string]Access{
+	"oneToClear": W1C, "oneToSet": W1S, "oneToToggle": W1T,
+	"zeroToClear": W0C, "zeroToSet": W0S, "clear": WC, "set": WS,
+}
+
+// AccessType maps the access wording of the source description (SVD,
+// IP-XACT, SystemRDL or the short names) to the write behaviour.
+func (f *Field) AccessType() Access {
+	if a, ok := writeActions[f.WriteAction]; ok {
+		return a
+	}
+	if a, ok := ParseAccess(f.Access); ok {
+		return a
+	}
+	name := strings.ToUpper(f.Name)
+	if strings.HasPrefix(name, "RESERVED") || strings.HasPrefix(name, "RSVD") {
+		return Reserved
+	}
+	readOnly := f.Access == "read-only"
+	switch f.ReadAction {
+	case "clear":
+		if readOnly {
+			return RC
+		}
+	case "set":
+		if readOnly {
+			return RS
+		}
+	}
+	switch f.Access {
+	case "read-only":
+		return RO
+	case "write-only", "writeOnce":
+		return WO
+	}
+	return RW
+}
+
+// WriteResult is the value the register holds after v is written to it.
+// Without a layout every bit is RW; with one, bits outside all fields are
+// reserved and keep their value.
+func (r *Register) WriteResult(v *big.Int) *big.Int {
+	written := new(big.Int).And(v, &r.mask)
+	if r.layout == nil || len(r.layout.Fields) == 0 {
+		return written
+	}
+	cur := &r.value
+	res := new(big.Int)
+	covered := new(big.Int)
+	for i := range r.layout.Fields {
+		f := &r.layout.Fields[i]
+		mask := f.Mask()
+		covered.Or(covered, mask)
+		bits := new(big.Int)
+		switch f.AccessType() {
+		case RW, WO:
+			bits.Set(written)
+		case W1C:
+			bits.AndNot(cur, written)
+		case W1S:
+			bits.Or(cur, written)
+		case W1T:
+			bits.Xor(cur, written)
+		case W0C:
+			bits.And(cur, written)
+		case W0S:
+			bits.AndNot(&r.mask, written)
+			bits.Or(bits, cur)
+		case WC:
+		case WS:
+			bits.Set(mask)
+		default:
+			bits.Set(cur)
+		}
+		res.Or(res, bits.And(bits, mask))
+	}
+	kept := new(big.Int).AndNot(cur, covered)
+	return res.Or(res, kept).And(res, &r.mask)
+}
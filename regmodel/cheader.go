@@ -0,0 +1,427 @@
+package regmodel
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	cDefine     = regexp.MustCompile(`^\s*#\s*define\s+([A-Za-z_]\w*)(\([^)]*\))?\s*(.*)$`)
+	cMaskSuffix = []string{"_MASK", "_MSK", "_Msk", "_msk", "_mask"}
+	cPosSuffix  = []string{"_SHIFT", "_SHFT", "_SFT", "_POS", "_Pos", "_pos", "_shift"}
+	cWidthSuf   = []string{"_WIDTH", "_Width", "_LEN", "_width"}
+	cRegSuffix  = []string{"_OFFSET", "_OFFS", "_OFS", "_REG", "_ADDR"}
+	cFieldFn    = regexp.MustCompile(`^(FIELD_PREP|FIELD_GET)\s*\((.*)\)$`)
+)
+
+type cMacro struct {
+	name    string
+	value   string
+	comment string
+	line    int
+	num     *big.Int
+	err     error
+	busy    bool
+}
+
+type cHeader struct {
+	file   string
+	macros map[string]*cMacro
+	order  []*cMacro
+	errs   ErrorList
+}
+
+type cField struct {
+	name    string
+	mask    *big.Int
+	shift   *cMacro
+	width   *cMacro
+	macro   *cMacro
+	comment string
+}
+
+func (h *cHeader) pos(m *cMacro) string {
+	return fmt.Sprintf("%s:%d %s", h.file, m.line, m.name)
+}
+
+// value evaluates a macro, expanding the macros it refers to.
+func (h *cHeader) value(m *cMacro) (*big.Int, error) {
+	if m.num != nil || m.err != nil {
+		return m.num, m.err
+	}
+	if m.busy {
+		return nil, fmt.Errorf("循环引用")
+	}
+	m.busy = true
+	m.num, m.err = Eval(m.value, func(name string) (*big.Int, bool) {
+		ref, ok := h.macros[name]
+		if !ok {
+			return nil, false
+		}
+		num, err := h.value(ref)
+		return num, err == nil
+	})
+	m.busy = false
+	return m.num, m.err
+}
+
+// fieldMask returns the mask argument of a macro body that is a FIELD_PREP
+// or FIELD_GET call, optionally in parentheses.
+func fieldMask(body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	for strings.HasPrefix(body, "(") && strings.HasSuffix(body, ")") {
+		body = strings.TrimSpace(body[1 : len(body)-1])
+	}
+	match := cFieldFn.FindStringSubmatch(body)
+	if match == nil {
+		return "", false
+	}
+	depth := 0
+	for i, c := range match[2] {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return "", false
+			}
+		case ',':
+			if depth == 0 {
+				return strings.TrimSpace(match[2][:i]), true
+			}
+		}
+	}
+	return "", false
+}
+
+func trimSuffix(name string, suffixes []string) (string, bool) {
+	for _, s := range suffixes {
+		if strings.HasSuffix(name, s) && len(name) > len(s) {
+			return strings.TrimSuffix(name, s), true
+		}
+	}
+	return name, false
+}
+
+// cLines joins continuation lines and drops block comments, keeping the
+// comment text that ends a #define as its description.
+func cLines(src string) ([]string, []string) {
+	src = strings.ReplaceAll(src, "\\\r\n", " ")
+	src = strings.ReplaceAll(src, "\\\n", " ")
+	lines := strings.Split(src, "\n")
+	comments := make([]string, len(lines))
+	inComment := false
+	for n, line := range lines {
+		var code, note strings.Builder
+		for i := 0; i < len(line); {
+			rest := line[i:]
+			switch {
+			case inComment:
+				end := strings.Index(rest, "*/")
+				if end < 0 {
+					note.WriteString(rest)
+					i = len(line)
+					continue
+				}
+				note.WriteString(rest[:end])
+				inComment = false
+				i += end + 2
+			case strings.HasPrefix(rest, "/*"):
+				inComment = true
+				i += 2
+			case strings.HasPrefix(rest, "//"):
+				note.WriteString(rest[2:])
+				i = len(line)
+			default:
+				code.WriteByte(line[i])
+				i++
+			}
+		}
+		lines[n] = code.String()
+		comments[n] = strings.Trim(strings.TrimSpace(note.String()), "*!< ")
+	}
+	return lines, comments
+}
+
+// LoadCHeader scans #define lines of a C header for register fields:
+// NAME_MASK/_MSK/_Msk masks, NAME_SHIFT/_POS/_Pos positions, NAME_WIDTH and
+// masks written as BIT(n) or GENMASK(h, l); a function-like macro that is a
+// FIELD_PREP or FIELD_GET call defines a field by its mask. A macro whose name prefixes a
+// field is its register (its value the offset); NAME_OFFSET/_REG/_ADDR mark
+// registers too. Other macros under a field become enumerated values, except
+// NAME_<n> single bit aliases; macros that only name another recognised
+// macro are aliases. Every macro that is not used is listed in the returned
+// ErrorList.
+func LoadCHeader(r io.Reader) (*RegisterMap, error) {
+	file := "<header>"
+	if named, ok := r.(interface{ Name() string }); ok {
+		file = filepath.Base(named.Name())
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h := &cHeader{file: file, macros: make(map[string]*cMacro)}
+	lines, comments := cLines(string(src))
+	var fns []*cMacro
+	for n, line := range lines {
+		match := cDefine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		m := &cMacro{name: match[1], value: strings.TrimSpace(match[3]), comment: comments[n], line: n + 1}
+		if match[2] != "" {
+			if mask, ok := fieldMask(m.value); ok {
+				m.value = mask
+				fns = append(fns, m)
+			} else {
+				h.errs.Add(h.pos(m), "未识别的宏: 带参数")
+			}
+			continue
+		}
+		h.macros[m.name] = m
+		h.order = append(h.order, m)
+	}
+	used := make(map[*cMacro]bool)
+	fields := make(map[string]*cField)
+	field := func(name string) *cField {
+		f, ok := fields[name]
+		if !ok {
+			f = &cField{name: name}
+			fields[name] = f
+		}
+		return f
+	}
+	for _, m := range h.order {
+		if base, ok := trimSuffix(m.name, cMaskSuffix); ok {
+			num, err := h.value(m)
+			if err != nil {
+				h.errs.Add(h.pos(m), "%v", err)
+				used[m] = true
+				continue
+			}
+			f := field(base)
+			f.mask, f.macro, used[m] = num, m, true
+			if f.comment == "" {
+				f.comment = m.comment
+			}
+		} else if base, ok := trimSuffix(m.name, cPosSuffix); ok {
+			f := field(base)
+			f.shift, used[m] = m, true
+		} else if base, ok := trimSuffix(m.name, cWidthSuf); ok {
+			f := field(base)
+			f.width, used[m] = m, true
+		} else if (strings.Contains(m.value, "BIT(") || strings.Contains(m.value, "BIT_ULL(") || strings.Contains(m.value, "GENMASK")) &&
+			!strings.Contains(m.value, "FIELD_") {
+			if _, ok := fields[m.name]; ok {
+				continue
+			}
+			num, err := h.value(m)
+			if err != nil {
+				h.errs.Add(h.pos(m), "%v", err)
+				used[m] = true
+				continue
+			}
+			f := field(m.name)
+			f.mask, f.macro, f.comment, used[m] = num, m, m.comment, true
+		}
+	}
+	// FIELD_PREP(NAME_MASK, x) wraps a field that is already known
+	for _, m := range fns {
+		if ref, ok := h.macros[m.value]; ok && used[ref] {
+			continue
+		}
+		if _, ok := fields[m.name]; ok {
+			continue
+		}
+		num, err := h.value(m)
+		if err != nil {
+			h.errs.Add(h.pos(m), "%v", err)
+			continue
+		}
+		f := field(m.name)
+		f.mask, f.macro, f.comment = num, m, m.comment
+	}
+	layouts := h.layouts(fields, used)
+	regMap := &RegisterMap{Name: strings.TrimSuffix(file, filepath.Ext(file))}
+	bank := &Bank{Name: regMap.Name}
+	bank.Registers = layouts
+	regMap.Banks = []*Bank{bank}
+	// aliases of recognised macros, e.g. #define TIM_CR1_CKD TIM_CR1_CKD_Msk
+	for changed := true; changed; {
+		changed = false
+		for _, m := range h.order {
+			if ref, ok := h.macros[m.value]; ok && !used[m] && used[ref] {
+				used[m], changed = true, true
+			}
+		}
+	}
+	for _, m := range h.order {
+		if !used[m] {
+			h.errs.Add(h.pos(m), "未识别的宏")
+		}
+	}
+	return validated(regMap, h.errs)
+}
+
+// bits converts the collected macros of a field into its bit range.
+func (h *cHeader) bits(f *cField) (int, int, bool) {
+	shift := -1
+	if f.shift != nil {
+		num, err := h.value(f.shift)
+		if err != nil || !num.IsInt64() || num.Sign() < 0 || num.Int64() > 4096 {
+			h.errs.Add(h.pos(f.shift), "无效移位")
+			return 0, 0, false
+		}
+		shift = int(num.Int64())
+	}
+	width := 1
+	if f.width != nil {
+		num, err := h.value(f.width)
+		if err != nil || !num.IsInt64() || num.Sign() <= 0 || num.Int64() > 4096 {
+			h.errs.Add(h.pos(f.width), "无效宽度")
+			return 0, 0, false
+		}
+		width = int(num.Int64())
+	}
+	if f.mask == nil {
+		if shift < 0 {
+			return 0, 0, false
+		}
+		return shift + width - 1, shift, true
+	}
+	mask := new(big.Int).Set(f.mask)
+	if mask.Sign() <= 0 {
+		h.errs.Add(h.pos(f.macro), "无效掩码")
+		return 0, 0, false
+	}
+	lsb := int(mask.TrailingZeroBits())
+	// masks given unshifted next to a shift, e.g. MASK 0xF / SHIFT 4
+	if shift > lsb {
+		mask.Lsh(mask, uint(shift))
+		lsb += shift
+	}
+	msb := mask.BitLen() - 1
+	if new(big.Int).Lsh(Mask(msb-lsb+1), uint(lsb)).Cmp(mask) != 0 {
+		h.errs.Add(h.pos(f.macro), "掩码0x%s不连续", f.mask.Text(16))
+		return 0, 0, false
+	}
+	return msb, lsb, true
+}
+
+func (h *cHeader) layouts(fields map[string]*cField, used map[*cMacro]bool) []*Layout {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// registers: plain macros that prefix a field, or carry a register suffix
+	regs := make(map[string]*cMacro)
+	for _, m := range h.order {
+		if used[m] {
+			continue
+		}
+		if base, ok := trimSuffix(m.name, cRegSuffix); ok {
+			regs[base] = m
+			continue
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, m.name+"_") {
+				regs[m.name] = m
+				break
+			}
+		}
+	}
+	var layouts []*Layout
+	byName := make(map[string]*Layout)
+	for _, name := range names {
+		f := fields[name]
+		msb, lsb, ok := h.bits(f)
+		if !ok {
+			continue
+		}
+		reg := ""
+		for r := range regs {
+			if strings.HasPrefix(name, r+"_") && len(r) > len(reg) {
+				reg = r
+			}
+		}
+		if reg == "" {
+			if ix := strings.LastIndex(name, "_"); ix > 0 {
+				reg = name[:ix]
+			} else {
+				reg = name
+			}
+		}
+		layout, ok := byName[reg]
+		if !ok {
+			layout = &Layout{Name: reg, Width: 32, Reset: new(big.Int)}
+			if m, ok := regs[reg]; ok {
+				used[m] = true
+				layout.Description = m.comment
+				if num, err := h.value(m); err == nil && num.IsUint64() {
+					layout.Offset = num.Uint64()
+				}
+			}
+			byName[reg] = layout
+			layouts = append(layouts, layout)
+		}
+		fieldName := strings.TrimPrefix(strings.TrimPrefix(name, reg), "_")
+		if fieldName == "" {
+			fieldName = name
+		}
+		field := Field{Name: fieldName, Msb: msb, Lsb: lsb, Description: f.comment}
+		h.enums(name, &field, used)
+		layout.Fields = append(layout.Fields, field)
+		for layout.Width <= msb {
+			layout.Width *= 2
+		}
+	}
+	for _, layout := range layouts {
+		sort.SliceStable(layout.Fields, func(i, j int) bool {
+			return layout.Fields[i].Lsb > layout.Fields[j].Lsb
+		})
+	}
+	sort.SliceStable(layouts, func(i, j int) bool {
+		return layouts[i].Offset < layouts[j].Offset
+	})
+	return layouts
+}
+
+// enums collects NAME_VALUE macros below a field. Values that fit the field
+// are taken as they are, larger ones must already be shifted into place.
+// NAME_<n> holding bit n of the field, as CMSIS defines TIM_CR1_CKD_0 and
+// TIM_CR1_CKD_1, names a single bit and is no value.
+func (h *cHeader) enums(name string, f *Field, used map[*cMacro]bool) {
+	mask := f.Mask()
+	for _, m := range h.order {
+		if used[m] || !strings.HasPrefix(m.name, name+"_") {
+			continue
+		}
+		num, err := h.value(m)
+		if err != nil || num.Sign() < 0 {
+			continue
+		}
+		suffix := strings.TrimPrefix(m.name, name+"_")
+		if n, err := strconv.Atoi(suffix); err == nil && n >= 0 && n < f.Width() &&
+			num.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(f.Lsb+n))) == 0 {
+			used[m] = true
+			continue
+		}
+		value := num
+		if num.BitLen() > f.Width() {
+			if new(big.Int).AndNot(num, mask).Sign() != 0 {
+				continue
+			}
+			value = new(big.Int).Rsh(num, uint(f.Lsb))
+		}
+		used[m] = true
+		addEnum(&h.errs, h.pos(m), f, suffix, value.String(), m.comment)
+	}
+}
@@ -0,0 +1,86 @@
+package regmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadCHeaderMacros(t *testing.T) {
+	src := `
+#define UART_CTRL_OFFSET   0x04
+#define UART_CTRL_MODE_MASK  0x30  /* mode */
+#define UART_CTRL_MODE_SHIFT 4
+#define UART_CTRL_MODE_IDLE  0x00
+#define UART_CTRL_MODE_RUN   0x20
+#define UART_CTRL_EN         BIT(0)
+#define UART_CTRL_BAD_MASK   0x5
+#define UART_MAX(a, b)       ((a) > (b) ? (a) : (b))
+#define UART_CTRL_MODE_SET(x) FIELD_PREP(UART_CTRL_MODE_MASK, x)
+#define UART_BAUD_REG        0x08
+#define UART_BAUD_DIV(x)     (FIELD_PREP(GENMASK(11, 8), (x)))  /* divider */
+#define UART_BAUD_DIV_FAST   FIELD_PREP(GENMASK(11, 8), 3)
+`
+	m, err := LoadCHeader(strings.NewReader(src))
+	msg := loaded(t, m, err)
+	for _, want := range []string{"UART_CTRL_BAD_MASK: 掩码0x5不连续", "UART_MAX: 未识别的宏: 带参数"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("errors %q, want %q", msg, want)
+		}
+	}
+	ctrl := layout(t, m, "<header>", "UART_CTRL")
+	if ctrl.Offset != 4 {
+		t.Errorf("UART_CTRL at 0x%x", ctrl.Offset)
+	}
+	mode := ctrl.Field("MODE")
+	if mode == nil || mode.Msb != 5 || mode.Lsb != 4 || mode.Description != "mode" || len(mode.Enums) != 2 || mode.Enums[1].Value.Int64() != 2 {
+		t.Errorf("MODE = %+v", mode)
+	}
+	if en := ctrl.Field("EN"); en == nil || en.Msb != 0 || en.Lsb != 0 {
+		t.Errorf("EN = %+v", en)
+	}
+	if len(ctrl.Fields) != 2 {
+		t.Errorf("UART_CTRL fields = %+v", ctrl.Fields)
+	}
+	baud := layout(t, m, "<header>", "UART_BAUD")
+	div := baud.Field("DIV")
+	if baud.Offset != 8 || div == nil || div.Msb != 11 || div.Lsb != 8 || div.Description != "divider" ||
+		len(div.Enums) != 1 || div.Enums[0].Name != "FAST" || div.Enums[0].Value.Int64() != 3 {
+		t.Errorf("UART_BAUD = %+v", baud)
+	}
+	if strings.Contains(msg, "UART_CTRL_MODE_SET") || strings.Contains(msg, "UART_BAUD") {
+		t.Errorf("FIELD_PREP macros reported: %q", msg)
+	}
+}
+
+const cHeaderSample = `
+/********************  Bit definition for TIM_CR1 register  ********************/
+#define TIM_CR1_CEN_Pos           (0U)
+#define TIM_CR1_CEN_Msk           (0x1UL << TIM_CR1_CEN_Pos)  /*!< 0x00000001 */
+#define TIM_CR1_CEN               TIM_CR1_CEN_Msk             /*!<Counter enable */
+#define TIM_CR1_DIR_Pos           (4U)
+#define TIM_CR1_DIR_Msk           (0x1UL << TIM_CR1_DIR_Pos)
+#define TIM_CR1_DIR               TIM_CR1_DIR_Msk             /*!<Direction */
+#define TIM_CR1_CKD_Pos           (8U)
+#define TIM_CR1_CKD_Msk           (0x3UL << TIM_CR1_CKD_Pos)  /*!< 0x00000300 */
+#define TIM_CR1_CKD               TIM_CR1_CKD_Msk             /*!<CKD[1:0] bits (clock division) */
+#define TIM_CR1_CKD_0             (0x1UL << TIM_CR1_CKD_Pos)  /*!< 0x00000100 */
+#define TIM_CR1_CKD_1             (0x2UL << TIM_CR1_CKD_Pos)  /*!< 0x00000200 */
+`
+
+func TestLoadCHeader(t *testing.T) {
+	m, err := LoadCHeader(strings.NewReader(cHeaderSample))
+	if msg := loaded(t, m, err); msg != "" {
+		t.Fatalf("errors: %s", msg)
+	}
+	cr1 := layout(t, m, "<header>", "TIM_CR1")
+	want := map[string][2]int{"CEN": {0, 0}, "DIR": {4, 4}, "CKD": {9, 8}}
+	if len(cr1.Fields) != len(want) {
+		t.Fatalf("fields = %+v", cr1.Fields)
+	}
+	for name, bits := range want {
+		f := cr1.Field(name)
+		if f == nil || f.Msb != bits[0] || f.Lsb != bits[1] || len(f.Enums) != 0 {
+			t.Errorf("%s = %+v, want [%d:%d] without enums", name, f, bits[0], bits[1])
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package regmodel
+
+import "math/big"
+
+// Comparison is the set of rows shown together; a bit is highlighted in the
+// header when the rows disagree on it.
+type Comparison struct {
+	rows []*Register
+}
+
+func NewComparison(rows ...*Register) *Comparison {
+	return &Comparison{rows: rows}
+}
+
+func (c *Comparison) Rows() []*Register {
+	return c.rows
+}
+
+func (c *Comparison) Len() int {
+	return len(c.rows)
+}
+
+func (c *Comparison) Add(r *Register) {
+	c.rows = append(c.rows, r)
+}
+
+func (c *Comparison) Remove(i int) {
+	if i < 0 || i >= len(c.rows) {
+		return
+	}
+	c.rows = append(c.rows[:i], c.rows[i+1:]...)
+}
+
+// DiffMask has a 1 for every bit where at least two rows differ. Bits above
+// a row's width are ignored for that row.
+func (c *Comparison) DiffMask() *big.Int {
+	ones, zeros := new(big.Int), new(big.Int)
+	var z big.Int
+	for _, r := range c.rows {
+		ones.Or(ones, &r.value)
+		z.Xor(&r.value, &r.mask)
+		zeros.Or(zeros, &z)
+	}
+	return ones.And(ones, zeros)
+}
+
+func (c *Comparison) Differs(i int) bool {
+	return c.DiffMask().Bit(i) == 1
+}
+
+// Xor is the difference mask of the first two rows; a missing row counts
+// as 0.
+func (c *Comparison) Xor() *big.Int {
+	x := new(big.Int)
+	for i := 0; i < 2 && i < len(c.rows); i++ {
+		x.Xor(x, &c.rows[i].value)
+	}
+	return x
+}
+
+// RowOp is a bitwise operation between two rows A and B.
+type RowOp struct {
+	Name  string
+	Apply func(z, a, b *big.Int) *big.Int
+}
+
+var RowOps = []RowOp{
+	{"A & B", (*big.Int).And},
+	{"A | B", (*big.Int).Or},
+	{"A ^ B", (*big.Int).Xor},
+	{"A & ~B (A有B无)", (*big.Int).AndNot},
+	{"B & ~A (B有A无)", func(z, a, b *big.Int) *big.Int {
+		return z.AndNot(b, a)
+	}},
+}
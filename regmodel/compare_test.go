@@ -0,0 +1,63 @@
+package regmodel
+
+import (
+	"math/big"
+	"testing"
+)
+
+func row(width int, value int64) *Register {
+	r := NewRegister(width)
+	r.SetValue(big.NewInt(value))
+	return r
+}
+
+func TestComparison(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []*Register
+		diff int64
+		xor  int64
+	}{
+		{"single row", []*Register{row(8, 0x5a)}, 0, 0x5a},
+		{"equal rows", []*Register{row(8, 0x5a), row(8, 0x5a)}, 0, 0},
+		{"two rows", []*Register{row(8, 0xf0), row(8, 0x3c)}, 0xcc, 0xcc},
+		{"third row only in mask", []*Register{row(8, 1), row(8, 1), row(8, 3)}, 2, 0},
+		{"narrow row ignores high bits", []*Register{row(4, 0xf), row(8, 0xff)}, 0, 0xf0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewComparison(tt.rows...)
+			if got := c.DiffMask().Int64(); got != tt.diff {
+				t.Errorf("DiffMask = %#x, want %#x", got, tt.diff)
+			}
+			if got := c.Xor().Int64(); got != tt.xor {
+				t.Errorf("Xor = %#x, want %#x", got, tt.xor)
+			}
+		})
+	}
+}
+
+func TestComparisonRemove(t *testing.T) {
+	c := NewComparison(row(8, 1), row(8, 2), row(8, 4))
+	c.Remove(1)
+	c.Remove(5)
+	if c.Len() != 2 || c.Rows()[1].Value().Int64() != 4 {
+		t.Errorf("Remove(1) left %d rows", c.Len())
+	}
+	if !c.Differs(2) || c.Differs(1) {
+		t.Errorf("Differs after Remove: mask %#x", c.DiffMask())
+	}
+}
+
+func TestRowOps(t *testing.T) {
+	a, b := big.NewInt(0xc), big.NewInt(0xa)
+	want := []int64{0x8, 0xe, 0x6, 0x4, 0x2}
+	if len(RowOps) != len(want) {
+		t.Fatalf("%d row ops, want %d", len(RowOps), len(want))
+	}
+	for i, op := range RowOps {
+		if got := op.Apply(new(big.Int), a, b).Int64(); got != want[i] {
+			t.Errorf("%s = %#x, want %#x", op.Name, got, want[i])
+		}
+	}
+}
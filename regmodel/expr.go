@@ -0,0 +1,359 @@
+package regmodel
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ExprError is an evaluation error at byte offset Pos of the expression.
+type ExprError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("第%d个字符: %s", e.Pos+1, e.Msg)
+}
+
+// Lookup resolves a name used in an expression.
+type Lookup func(name string) (*big.Int, bool)
+
+type exprParser struct {
+	src    string
+	pos    int
+	base   int
+	lookup Lookup
+}
+
+// Eval evaluates a C style integer expression: decimal, 0x/0b/0o, #binary and
+// Verilog sized literals with '_' separators (C suffixes U/L are ignored, the
+// k/M/G/T suffixes of ParseNumber are not accepted), + - * / % & | ^ ~ << >>,
+// parentheses, C casts, BIT(n), GENMASK(h, l), FIELD_PREP(mask, v),
+// FIELD_GET(mask, v) and names resolved by lookup. The result can be
+// negative; callers mask it to their width.
+func Eval(expr string, lookup Lookup) (*big.Int, error) {
+	return EvalBase(expr, 10, lookup)
+}
+
+// EvalBase is Eval with literals without a prefix read in base. Words made
+// only of digits of that base are numbers, so in base 16 "ff" is 255, unless
+// lookup knows the name. A 0x/0b/0o prefix wins over the digits of base, so
+// "0b11" is 3 and "0x1f" is 31 in base 16 and 36 alike.
+func EvalBase(expr string, base int, lookup Lookup) (*big.Int, error) {
+	p := &exprParser{src: expr, base: base, lookup: lookup}
+	num, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(); p.pos < len(p.src) {
+		return nil, p.errorf("多余的 %q", p.src[p.pos:])
+	}
+	return num, nil
+}
+
+var exprLevels = [][]string{
+	{"|"}, {"^"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"},
+}
+
+func (p *exprParser) errorf(format string, a ...interface{}) error {
+	return &ExprError{Pos: p.pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) operator(ops []string) string {
+	p.skip()
+	for _, op := range ops {
+		rest := p.src[p.pos:]
+		// keep "<<" from matching "<" and "|" from matching "||"
+		if strings.HasPrefix(rest, op) && !strings.HasPrefix(rest[len(op):], op[:1]) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) binary(level int) (*big.Int, error) {
+	if level == len(exprLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.operator(exprLevels[level])
+		if op == "" {
+			return left, nil
+		}
+		at := p.pos
+		p.pos += len(op)
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "|":
+			left.Or(left, right)
+		case "^":
+			left.Xor(left, right)
+		case "&":
+			left.And(left, right)
+		case "<<", ">>":
+			if right.Sign() < 0 || right.Cmp(big.NewInt(4096)) > 0 {
+				return nil, &ExprError{Pos: at, Msg: "无效移位 " + right.String()}
+			}
+			if op == "<<" {
+				left.Lsh(left, uint(right.Uint64()))
+			} else {
+				left.Rsh(left, uint(right.Uint64()))
+			}
+		case "+":
+			left.Add(left, right)
+		case "-":
+			left.Sub(left, right)
+		case "*":
+			left.Mul(left, right)
+		case "/", "%":
+			if right.Sign() == 0 {
+				return nil, &ExprError{Pos: at, Msg: "除数为0"}
+			}
+			if op == "/" {
+				left.Quo(left, right)
+			} else {
+				left.Rem(left, right)
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() (*big.Int, error) {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("缺少数值")
+	}
+	switch c := p.src[p.pos]; c {
+	case '-', '~', '+':
+		p.pos++
+		num, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case '-':
+			num.Neg(num)
+		case '~':
+			num.Not(num)
+		}
+		return num, nil
+	case '(':
+		start := p.pos
+		p.pos++
+		if p.cast() {
+			return p.unary()
+		}
+		num, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.skip(); p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, &ExprError{Pos: start, Msg: "括号不匹配"}
+		}
+		p.pos++
+		return num, nil
+	}
+	return p.primary()
+}
+
+// cast skips a C cast such as (uint32_t) or (unsigned long) after its '('.
+func (p *exprParser) cast() bool {
+	end := strings.IndexByte(p.src[p.pos:], ')')
+	if end < 0 {
+		return false
+	}
+	words := strings.Fields(p.src[p.pos : p.pos+end])
+	if len(words) == 0 {
+		return false
+	}
+	for _, w := range words {
+		switch {
+		case strings.HasSuffix(w, "_t"):
+		case w == "unsigned" || w == "signed" || w == "int" || w == "long" || w == "short" || w == "char":
+		default:
+			return false
+		}
+	}
+	p.pos += end + 1
+	return true
+}
+
+func isExprIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *exprParser) primary() (*big.Int, error) {
+	start := p.pos
+	c := p.src[p.pos]
+	if !isExprIdent(c) && c != '#' && c != '\'' {
+		return nil, p.errorf("意外的 %q", string(c))
+	}
+	p.pos++
+	for p.pos < len(p.src) && (isExprIdent(p.src[p.pos]) || p.src[p.pos] == '\'') {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+	if num, ok := p.literal(word); ok {
+		return num, nil
+	}
+	if c >= '0' && c <= '9' || c == '#' || c == '\'' {
+		if p.base != 10 && !prefixed(word) {
+			return nil, &ExprError{Pos: start, Msg: fmt.Sprintf("无效%d进制数值 %s", p.base, word)}
+		}
+		num, ok := number(word)
+		if !ok {
+			return nil, &ExprError{Pos: start, Msg: "无效数值 " + word}
+		}
+		return num, nil
+	}
+	if p.skip(); p.pos < len(p.src) && p.src[p.pos] == '(' {
+		return p.call(start, word)
+	}
+	if p.lookup != nil {
+		if num, ok := p.lookup(word); ok {
+			return new(big.Int).Set(num), nil
+		}
+	}
+	return nil, &ExprError{Pos: start, Msg: "未定义的名称 " + word}
+}
+
+// prefixed reports whether word carries its own base (0x.., 0b.., 0o..,
+// #.., 8'h..). The prefix wins in every base: in base 16 "0b1010" is 10, a
+// bare "0b" is still 11.
+func prefixed(word string) bool {
+	if strings.ContainsAny(word, "#'") {
+		return true
+	}
+	lower := strings.ToLower(word)
+	return len(lower) > 2 && lower[0] == '0' && strings.ContainsRune("xbo", rune(lower[1]))
+}
+
+// Prefixed reports whether s starts with a literal that has its own base;
+// front ends hand such input to EvalBase instead of reading it in the
+// current base.
+func Prefixed(s string) bool {
+	return prefixed(strings.TrimSpace(s))
+}
+
+// number reads a literal: decimal, 0x/0b/0o, #binary or Verilog sized, with
+// '_' separators and C suffixes U/L.
+func number(word string) (*big.Int, bool) {
+	str := strings.ReplaceAll(word, "_", "")
+	if strings.Contains(str, "'") {
+		// Verilog literals carry no suffixes, ParseNumber does not scale them
+		num, err := ParseNumber(str)
+		return num, err == nil
+	}
+	str = strings.TrimRight(str, "uUlL")
+	base := 10
+	lower := strings.ToLower(str)
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		str, base = str[2:], 16
+	case strings.HasPrefix(lower, "0b"):
+		str, base = str[2:], 2
+	case strings.HasPrefix(lower, "0o"):
+		str, base = str[2:], 8
+	case strings.HasPrefix(str, "#"):
+		str, base = str[1:], 2
+	}
+	num, ok := new(big.Int).SetString(str, base)
+	if !ok || num.Sign() < 0 {
+		return nil, false
+	}
+	return num, true
+}
+
+// literal reads a word without prefix in the parser's base.
+func (p *exprParser) literal(word string) (*big.Int, bool) {
+	if p.base == 10 || prefixed(word) {
+		return nil, false
+	}
+	if p.lookup != nil {
+		if _, ok := p.lookup(word); ok {
+			return nil, false
+		}
+	}
+	if strings.HasPrefix(word, "_") || strings.HasSuffix(word, "_") {
+		return nil, false
+	}
+	if p.skip(); p.pos < len(p.src) && p.src[p.pos] == '(' {
+		return nil, false
+	}
+	return new(big.Int).SetString(strings.ReplaceAll(word, "_", ""), p.base)
+}
+
+func (p *exprParser) call(start int, name string) (*big.Int, error) {
+	p.pos++
+	var args []*big.Int
+	for {
+		num, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, num)
+		p.skip()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, &ExprError{Pos: start, Msg: "括号不匹配"}
+		}
+		p.pos++
+		break
+	}
+	switch name {
+	case "BIT", "BIT_ULL", "GENMASK", "GENMASK_ULL":
+		for _, arg := range args {
+			if arg.Sign() < 0 || arg.Cmp(big.NewInt(4096)) > 0 {
+				return nil, &ExprError{Pos: start, Msg: name + ": 无效位号 " + arg.String()}
+			}
+		}
+	case "FIELD_PREP", "FIELD_GET":
+		if len(args) == 2 && args[0].Sign() <= 0 {
+			return nil, &ExprError{Pos: start, Msg: name + ": 无效掩码 " + args[0].String()}
+		}
+	}
+	switch name {
+	case "BIT", "BIT_ULL":
+		if len(args) == 1 {
+			return new(big.Int).Lsh(big.NewInt(1), uint(args[0].Uint64())), nil
+		}
+	case "GENMASK", "GENMASK_ULL":
+		if len(args) == 2 {
+			hi, lo := int(args[0].Int64()), int(args[1].Int64())
+			if hi < lo {
+				return nil, &ExprError{Pos: start, Msg: "GENMASK: 高位小于低位"}
+			}
+			return new(big.Int).Lsh(Mask(hi-lo+1), uint(lo)), nil
+		}
+	case "FIELD_PREP":
+		if len(args) == 2 {
+			num := new(big.Int).Lsh(args[1], args[0].TrailingZeroBits())
+			return num.And(num, args[0]), nil
+		}
+	case "FIELD_GET":
+		if len(args) == 2 {
+			num := new(big.Int).And(args[1], args[0])
+			return num.Rsh(num, args[0].TrailingZeroBits()), nil
+		}
+	default:
+		return nil, &ExprError{Pos: start, Msg: "未知函数 " + name}
+	}
+	return nil, &ExprError{Pos: start, Msg: name + ": 参数个数错误"}
+}
@@ -0,0 +1,90 @@
+package regmodel
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestEvalBase(t *testing.T) {
+	lookup := func(name string) (*big.Int, bool) {
+		switch name {
+		case "row1":
+			return big.NewInt(0xf0), true
+		case "cafe":
+			return big.NewInt(7), true
+		}
+		return nil, false
+	}
+	tests := []struct {
+		expr string
+		base int
+		want string
+		ok   bool
+	}{
+		{"1 + 2 * 3", 10, "7", true},
+		{"(1 + 2) * 3", 10, "9", true},
+		{"0x8000_0000 | (3 << 4) | BIT(2)", 10, "2147483700", true},
+		{"~0x1f & 0xff", 10, "224", true},
+		{"GENMASK(7, 4)", 10, "240", true},
+		{"GENMASK(3, 4)", 10, "", false},
+		{"FIELD_PREP(GENMASK(11, 8), 5)", 10, "1280", true},
+		{"FIELD_PREP(0xf00, 0x15)", 10, "1280", true},
+		{"FIELD_GET(GENMASK(11, 8), 0x1234)", 10, "2", true},
+		{"FIELD_PREP(0, 1)", 10, "", false},
+		{"FIELD_GET(0xf00)", 10, "", false},
+		{"(uint32_t)0x10UL", 10, "16", true},
+		{"row1 ^ 0xff", 10, "15", true},
+		{"-5", 10, "-5", true},
+		{"7 / 0", 10, "", false},
+		{"1 +", 10, "", false},
+		{"(1", 10, "", false},
+		{"foo", 10, "", false},
+		{"ff", 16, "255", true},
+		{"ff + 1", 16, "256", true},
+		{"cafe", 16, "7", true},
+		{"0x1f", 16, "31", true},
+		{"0o17", 16, "15", true},
+		{"101 & 11", 2, "1", true},
+		{"8'h1F", 16, "31", true},
+		{"#101", 10, "5", true},
+		// a 0x/0b/0o prefix wins over the digits of the base
+		{"0b1010", 16, "10", true},
+		{"0b11+0", 16, "3", true},
+		{"0B11", 36, "3", true},
+		{"0x1f", 36, "31", true},
+		{"0o17 + 1", 36, "16", true},
+		{"0b1f", 16, "", false},
+		{"0b", 16, "11", true},
+		{"b11", 16, "2833", true},
+		// the k/M/G/T scaling of map files is no expression syntax
+		{"2m", 10, "", false},
+		{"4k", 10, "", false},
+		{"2m", 16, "", false},
+	}
+	for _, tt := range tests {
+		num, err := EvalBase(tt.expr, tt.base, lookup)
+		if (err == nil) != tt.ok {
+			t.Errorf("EvalBase(%q, %d) error %v, want ok %v", tt.expr, tt.base, err, tt.ok)
+			continue
+		}
+		if tt.ok && num.String() != tt.want {
+			t.Errorf("EvalBase(%q, %d) = %s, want %s", tt.expr, tt.base, num, tt.want)
+		}
+	}
+}
+
+func TestPrefixed(t *testing.T) {
+	for in, want := range map[string]bool{"0b1010": true, " 0x1f": true, "8'hff": true, "#101": true, "0b": false, "b11": false, "1010": false} {
+		if got := Prefixed(in); got != want {
+			t.Errorf("Prefixed(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestEvalErrorPos(t *testing.T) {
+	_, err := Eval("1 + foo", nil)
+	e, ok := err.(*ExprError)
+	if !ok || e.Pos != 4 {
+		t.Errorf("error %v, want ExprError at 4", err)
+	}
+}
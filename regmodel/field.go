@@ -0,0 +1,156 @@
+package regmodel
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Field is a named bit range of a register, Msb >= Lsb. Access, WriteAction
+// and ReadAction keep the wording of the source description, e.g.
+// "read-write", "oneToClear", "clear"; Hardware is the SystemRDL hw access.
+type Field struct {
+	Name        string
+	Msb         int
+	Lsb         int
+	Description string
+	Access      string
+	WriteAction string
+	ReadAction  string
+	Hardware    string
+	Enums       []Enum
+}
+
+// Enum names one encoding of a field.
+type Enum struct {
+	Name        string
+	Value       *big.Int
+	Description string
+}
+
+func (f *Field) Width() int {
+	return f.Msb - f.Lsb + 1
+}
+
+func (f *Field) Contains(bit int) bool {
+	return bit >= f.Lsb && bit <= f.Msb
+}
+
+func (f *Field) Mask() *big.Int {
+	return new(big.Int).Lsh(Mask(f.Width()), uint(f.Lsb))
+}
+
+func (f *Field) Enum(value *big.Int) *Enum {
+	for i := range f.Enums {
+		if f.Enums[i].Value.Cmp(value) == 0 {
+			return &f.Enums[i]
+		}
+	}
+	return nil
+}
+
+func (f *Field) Range() string {
+	if f.Msb == f.Lsb {
+		return fmt.Sprint(f.Msb)
+	}
+	return fmt.Sprintf("%d:%d", f.Msb, f.Lsb)
+}
+
+// Layout describes the fields of one register.
+type Layout struct {
+	Name        string
+	Description string
+	Width       int
+	Offset      uint64
+	Access      string
+	Reset       *big.Int
+	Fields      []Field
+}
+
+func (l *Layout) FieldAt(bit int) *Field {
+	for i := range l.Fields {
+		if l.Fields[i].Contains(bit) {
+			return &l.Fields[i]
+		}
+	}
+	return nil
+}
+
+func (l *Layout) Field(name string) *Field {
+	for i := range l.Fields {
+		if l.Fields[i].Name == name {
+			return &l.Fields[i]
+		}
+	}
+	return nil
+}
+
+func ParseBitRange(s string, width int) (int, int, error) {
+	nums := strings.Split(s, ":")
+	if len(nums) > 2 {
+		return 0, 0, ErrInvalid
+	}
+	ix := make([]int, len(nums))
+	for i, num := range nums {
+		n, err := strconv.Atoi(strings.TrimSpace(num))
+		if err != nil || n < 0 || n >= width {
+			return 0, 0, ErrInvalid
+		}
+		ix[i] = n
+	}
+	msb, lsb := ix[0], ix[len(ix)-1]
+	if msb < lsb {
+		msb, lsb = lsb, msb
+	}
+	return msb, lsb, nil
+}
+
+// ParseLayout reads the manual field format, one field per line:
+//
+//	NAME MSB[:LSB] [ACCESS] [description]
+//
+// ACCESS is one of the short names of ParseAccess (RW, RO, W1C, ...).
+// Blank lines and lines starting with # are skipped.
+func ParseLayout(text string, width int) (*Layout, error) {
+	layout := &Layout{Width: width}
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("第%d行: 缺少位范围", n+1)
+		}
+		msb, lsb, err := ParseBitRange(parts[1], width)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: 无效位范围 %q", n+1, parts[1])
+		}
+		field := Field{Name: parts[0], Msb: msb, Lsb: lsb}
+		desc := parts[2:]
+		if len(desc) > 0 {
+			if _, ok := ParseAccess(desc[0]); ok && desc[0] == strings.ToUpper(desc[0]) {
+				field.Access, desc = desc[0], desc[1:]
+			}
+		}
+		field.Description = strings.Join(desc, " ")
+		layout.Fields = append(layout.Fields, field)
+	}
+	return layout, nil
+}
+
+func (l *Layout) String() string {
+	var sb strings.Builder
+	for _, f := range l.Fields {
+		fmt.Fprintf(&sb, "%s %s", f.Name, f.Range())
+		if a := f.AccessType(); a != RW {
+			fmt.Fprintf(&sb, " %s", a)
+		}
+		if f.Description != "" {
+			fmt.Fprintf(&sb, " %s", f.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
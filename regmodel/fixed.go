@@ -0,0 +1,140 @@
+package regmodel
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// QFormat is a fixed point layout Qm.n of Int integer and Frac fraction
+// bits. In signed formats the sign bit counts as an integer bit, so Q1.15 is
+// 16 bits wide.
+type QFormat struct {
+	Signed bool
+	Int    int
+	Frac   int
+}
+
+// ParseQFormat reads Qm.n and UQm.n, or the short forms Qn and UQn where
+// the integer bits are the rest of width.
+func ParseQFormat(s string, width int) (QFormat, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	q := QFormat{Signed: !strings.HasPrefix(text, "UQ")}
+	text = strings.TrimPrefix(text, "U")
+	if !strings.HasPrefix(text, "Q") {
+		return q, fmt.Errorf("无效定点格式 %q", s)
+	}
+	text = text[1:]
+	var err error
+	if ix := strings.IndexByte(text, '.'); ix >= 0 {
+		q.Int, err = strconv.Atoi(text[:ix])
+		if err == nil {
+			q.Frac, err = strconv.Atoi(text[ix+1:])
+		}
+	} else {
+		q.Frac, err = strconv.Atoi(text)
+		q.Int = width - q.Frac
+	}
+	if err != nil || q.Int < 0 || q.Frac < 0 || q.Width() < 1 {
+		return q, fmt.Errorf("无效定点格式 %q", s)
+	}
+	if q.Signed && q.Int < 1 {
+		return q, fmt.Errorf("%s: 有符号格式至少要有1位整数(符号位)", s)
+	}
+	return q, nil
+}
+
+func (q QFormat) Width() int {
+	return q.Int + q.Frac
+}
+
+func (q QFormat) String() string {
+	if q.Signed {
+		return fmt.Sprintf("Q%d.%d", q.Int, q.Frac)
+	}
+	return fmt.Sprintf("UQ%d.%d", q.Int, q.Frac)
+}
+
+// Raw is the integer held in the low Width() bits of v.
+func (q QFormat) Raw(v *big.Int) *big.Int {
+	if q.Signed {
+		return Signed(v, q.Width())
+	}
+	return new(big.Int).And(v, Mask(q.Width()))
+}
+
+func (q QFormat) scale() *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(q.Frac))
+}
+
+// Value is the exact real number the low Width() bits of v stand for.
+func (q QFormat) Value(v *big.Int) *big.Rat {
+	return new(big.Rat).SetFrac(q.Raw(v), q.scale())
+}
+
+// Resolution is the weight of the lowest bit, 2^-Frac.
+func (q QFormat) Resolution() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(1), q.scale())
+}
+
+func (q QFormat) rawRange() (*big.Int, *big.Int) {
+	if q.Signed {
+		max := Mask(q.Width() - 1)
+		return new(big.Int).Not(max), max
+	}
+	return new(big.Int), Mask(q.Width())
+}
+
+// Range is the smallest and largest value of the format.
+func (q QFormat) Range() (*big.Rat, *big.Rat) {
+	min, max := q.rawRange()
+	return new(big.Rat).SetFrac(min, q.scale()), new(big.Rat).SetFrac(max, q.scale())
+}
+
+// Quantize rounds x to the nearest value of the format, halves away from
+// zero, and saturates at the ends of the range.
+func (q QFormat) Quantize(x *big.Rat) (bits *big.Int, saturated bool) {
+	scaled := new(big.Rat).Mul(x, new(big.Rat).SetInt(q.scale()))
+	abs := new(big.Rat).Abs(scaled)
+	abs.Add(abs, big.NewRat(1, 2))
+	raw := new(big.Int).Quo(abs.Num(), abs.Denom())
+	if scaled.Sign() < 0 {
+		raw.Neg(raw)
+	}
+	min, max := q.rawRange()
+	if raw.Cmp(min) < 0 {
+		raw, saturated = min, true
+	} else if raw.Cmp(max) > 0 {
+		raw, saturated = max, true
+	}
+	return raw.And(raw, Mask(q.Width())), saturated
+}
+
+// ParseReal reads a decimal number such as -1.25 or 3e-3, or a fraction
+// such as 1/3.
+func ParseReal(s string) (*big.Rat, error) {
+	x, ok := new(big.Rat).SetString(strings.ReplaceAll(strings.TrimSpace(s), "_", ""))
+	if !ok {
+		return nil, fmt.Errorf("无效实数 %q", s)
+	}
+	return x, nil
+}
+
+// RealText writes x in decimal, exactly when it has at most digits fraction
+// digits.
+func RealText(x *big.Rat, digits int) string {
+	text := x.FloatString(digits)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	if text == "-0" {
+		return "0"
+	}
+	return text
+}
+
+// Text writes the value of v exactly; Frac fraction digits always suffice.
+func (q QFormat) Text(v *big.Int) string {
+	return RealText(q.Value(v), q.Frac)
+}
@@ -0,0 +1,215 @@
+package regmodel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// FloatFormat is an IEEE-754 style binary floating point layout: a sign bit,
+// Exp exponent bits and Frac fraction bits, from the top down.
+type FloatFormat struct {
+	Name string
+	Exp  int
+	Frac int
+}
+
+var (
+	Float16  = FloatFormat{"float16", 5, 10}
+	BFloat16 = FloatFormat{"bfloat16", 8, 7}
+	Float32  = FloatFormat{"float32", 8, 23}
+	Float64  = FloatFormat{"float64", 11, 52}
+)
+
+// FloatFor is the format of the given width: float16 (or bfloat16 when brain
+// is set), float32 or float64.
+func FloatFor(width int, brain bool) (FloatFormat, bool) {
+	switch width {
+	case 16:
+		if brain {
+			return BFloat16, true
+		}
+		return Float16, true
+	case 32:
+		return Float32, true
+	case 64:
+		return Float64, true
+	}
+	return FloatFormat{}, false
+}
+
+func (f FloatFormat) Width() int {
+	return 1 + f.Exp + f.Frac
+}
+
+func (f FloatFormat) bias() int {
+	return 1<<(f.Exp-1) - 1
+}
+
+// Float kinds
+const (
+	FloatZero = iota
+	FloatSubnormal
+	FloatNormal
+	FloatInf
+	FloatNaN
+)
+
+// FloatValue is a bit pattern taken apart by a FloatFormat.
+type FloatValue struct {
+	Format FloatFormat
+	Sign   uint
+	Exp    uint64 // biased exponent field
+	Frac   uint64 // fraction field
+	Kind   int
+	Value  float64 // exact; NaN for NaN patterns
+}
+
+// Decode splits the low Width() bits of v into sign, exponent and fraction.
+func (f FloatFormat) Decode(v *big.Int) FloatValue {
+	bits := new(big.Int).And(v, Mask(f.Width())).Uint64()
+	d := FloatValue{Format: f}
+	d.Frac = bits & (1<<uint(f.Frac) - 1)
+	d.Exp = bits >> uint(f.Frac) & (1<<uint(f.Exp) - 1)
+	d.Sign = uint(bits >> uint(f.Exp+f.Frac) & 1)
+	switch {
+	case d.Exp == 1<<uint(f.Exp)-1 && d.Frac == 0:
+		d.Kind, d.Value = FloatInf, math.Inf(1)
+	case d.Exp == 1<<uint(f.Exp)-1:
+		d.Kind, d.Value = FloatNaN, math.NaN()
+	case d.Exp == 0 && d.Frac == 0:
+		d.Kind = FloatZero
+	case d.Exp == 0:
+		d.Kind = FloatSubnormal
+		d.Value = math.Ldexp(float64(d.Frac), 1-f.bias()-f.Frac)
+	default:
+		d.Kind = FloatNormal
+		d.Value = math.Ldexp(float64(d.Frac|1<<uint(f.Frac)), int(d.Exp)-f.bias()-f.Frac)
+	}
+	if d.Sign == 1 {
+		d.Value = math.Copysign(d.Value, -1)
+	}
+	return d
+}
+
+// Quiet reports whether a NaN is quiet, i.e. the top fraction bit is set.
+func (d FloatValue) Quiet() bool {
+	return d.Frac>>uint(d.Format.Frac-1) == 1
+}
+
+// Payload is the fraction of a NaN below the quiet bit.
+func (d FloatValue) Payload() uint64 {
+	return d.Frac & (1<<uint(d.Format.Frac-1) - 1)
+}
+
+// String is the shortest decimal that encodes back to the same bits, or
+// ±Inf / NaN.
+func (d FloatValue) String() string {
+	switch d.Kind {
+	case FloatNaN:
+		return "NaN"
+	case FloatInf:
+		if d.Sign == 1 {
+			return "-Inf"
+		}
+		return "+Inf"
+	case FloatZero:
+		if d.Sign == 1 {
+			return "-0"
+		}
+		return "0"
+	}
+	bits := d.Format.encode(d.Value)
+	for digits := 1; digits < 17; digits++ {
+		text := strconv.FormatFloat(d.Value, 'g', digits, 64)
+		x, _ := strconv.ParseFloat(text, 64)
+		if d.Format.encode(x) == bits {
+			// 'g' at full precision avoids exponents for numbers like 65500
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		}
+	}
+	return strconv.FormatFloat(d.Value, 'g', -1, 64)
+}
+
+// Detail describes the fields: kind, sign, exponent and fraction, the exact
+// value and the payload of a NaN.
+func (d FloatValue) Detail() string {
+	f := d.Format
+	var kind string
+	switch d.Kind {
+	case FloatZero:
+		kind = "零"
+	case FloatSubnormal:
+		kind = "非规格化"
+	case FloatNormal:
+		kind = "规格化"
+	case FloatInf:
+		kind = "无穷大"
+	case FloatNaN:
+		kind = "sNaN"
+		if d.Quiet() {
+			kind = "qNaN"
+		}
+		kind += fmt.Sprintf(" 载荷0x%x", d.Payload())
+	}
+	exp := fmt.Sprint(d.Exp)
+	switch d.Kind {
+	case FloatNormal:
+		exp += fmt.Sprintf(" (2^%d)", int(d.Exp)-f.bias())
+	case FloatSubnormal:
+		exp += fmt.Sprintf(" (2^%d)", 1-f.bias())
+	}
+	text := fmt.Sprintf("%s %s\n符号 %d\n指数 %s\n尾数 0x%x", f.Name, kind, d.Sign, exp, d.Frac)
+	if d.Kind == FloatNormal || d.Kind == FloatSubnormal {
+		text += "\n精确值 " + strconv.FormatFloat(d.Value, 'g', -1, 64)
+	}
+	return text
+}
+
+// Encode parses a decimal or hexadecimal float, Inf or NaN, and rounds it
+// to the nearest value of the format, ties to even.
+func (f FloatFormat) Encode(s string) (*big.Int, error) {
+	x, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), "_", ""), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("无效浮点数 %q", s)
+	}
+	return new(big.Int).SetUint64(f.encode(x)), nil
+}
+
+func (f FloatFormat) encode(x float64) uint64 {
+	if f == Float64 {
+		return math.Float64bits(x)
+	}
+	var sign uint64
+	if math.Signbit(x) {
+		sign = 1 << uint(f.Exp+f.Frac)
+		x = -x
+	}
+	expMax := uint64(1)<<uint(f.Exp) - 1
+	switch {
+	case math.IsNaN(x):
+		return sign | expMax<<uint(f.Frac) | 1<<uint(f.Frac-1)
+	case math.IsInf(x, 0):
+		return sign | expMax<<uint(f.Frac)
+	case x == 0:
+		return sign
+	}
+	_, e := math.Frexp(x)
+	e-- // x = 1.m * 2^e
+	var bits uint64
+	if min := 1 - f.bias(); e < min {
+		// subnormal; rounding up to 1.0 gives the smallest normal
+		bits = uint64(math.RoundToEven(math.Ldexp(x, f.Frac-min)))
+	} else {
+		// the rounded significand carries into the exponent field
+		q := uint64(math.RoundToEven(math.Ldexp(x, f.Frac-e)))
+		bits = uint64(e+f.bias())<<uint(f.Frac) + q - 1<<uint(f.Frac)
+	}
+	if bits >= expMax<<uint(f.Frac) {
+		bits = expMax << uint(f.Frac)
+	}
+	return sign | bits
+}
@@ -0,0 +1,85 @@
+package regmodel
+
+import "time"
+
+// HistoryEntry is a state of the analyzer and the edit that led to it.
+type HistoryEntry struct {
+	Name  string
+	Time  time.Time
+	State *Session
+}
+
+// History is an undo list of session snapshots. The current entry is the
+// state shown; the entries after it can be redone until something new is
+// pushed.
+type History struct {
+	entries []HistoryEntry
+	pos     int
+	Limit   int
+}
+
+// Push adds state after the current entry, dropping the redo entries and,
+// beyond Limit, the oldest ones.
+func (h *History) Push(name string, state *Session) {
+	if len(h.entries) > 0 {
+		h.entries = h.entries[:h.pos+1]
+	}
+	h.entries = append(h.entries, HistoryEntry{name, time.Now(), state})
+	if h.Limit > 0 && len(h.entries) > h.Limit {
+		h.entries = h.entries[len(h.entries)-h.Limit:]
+	}
+	h.pos = len(h.entries) - 1
+}
+
+// Replace overwrites the current entry and drops the redo entries; used to
+// merge a run of edits such as typing into one entry.
+func (h *History) Replace(name string, state *Session) {
+	if len(h.entries) == 0 {
+		h.Push(name, state)
+		return
+	}
+	h.entries = h.entries[:h.pos+1]
+	h.entries[h.pos] = HistoryEntry{name, time.Now(), state}
+}
+
+// Current is the entry shown, nil before the first Push.
+func (h *History) Current() *HistoryEntry {
+	if len(h.entries) == 0 {
+		return nil
+	}
+	return &h.entries[h.pos]
+}
+
+func (h *History) Entries() []HistoryEntry {
+	return h.entries
+}
+
+func (h *History) Pos() int {
+	return h.pos
+}
+
+func (h *History) CanUndo() bool {
+	return h.pos > 0
+}
+
+func (h *History) CanRedo() bool {
+	return h.pos < len(h.entries)-1
+}
+
+// Jump makes entry i current and returns its state, nil when i is out of
+// range.
+func (h *History) Jump(i int) *Session {
+	if i < 0 || i >= len(h.entries) {
+		return nil
+	}
+	h.pos = i
+	return h.entries[i].State
+}
+
+func (h *History) Undo() *Session {
+	return h.Jump(h.pos - 1)
+}
+
+func (h *History) Redo() *Session {
+	return h.Jump(h.pos + 1)
+}
@@ -0,0 +1,290 @@
+package regmodel
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// The structs match on local names only, so both the spirit (1685-2009) and
+// ipxact (1685-2014/2022) namespaces are accepted.
+type ipxComponent struct {
+	Name       string      `xml:"name"`
+	MemoryMaps []ipxMemMap `xml:"memoryMaps>memoryMap"`
+}
+
+type ipxMemMap struct {
+	Name          string       `xml:"name"`
+	Description   string       `xml:"description"`
+	AddressBlocks []ipxBlock   `xml:"addressBlock"`
+	Banks         []ipxMemBank `xml:"bank"`
+}
+
+type ipxMemBank struct {
+	Name          string       `xml:"name"`
+	BaseAddress   string       `xml:"baseAddress"`
+	AddressBlocks []ipxBlock   `xml:"addressBlock"`
+	Banks         []ipxMemBank `xml:"bank"`
+}
+
+type ipxBlock struct {
+	Name          string        `xml:"name"`
+	Description   string        `xml:"description"`
+	BaseAddress   string        `xml:"baseAddress"`
+	Width         string        `xml:"width"`
+	Access        string        `xml:"access"`
+	Registers     []ipxRegister `xml:"register"`
+	RegisterFiles []ipxRegFile  `xml:"registerFile"`
+}
+
+type ipxRegFile struct {
+	Name          string        `xml:"name"`
+	AddressOffset string        `xml:"addressOffset"`
+	Dim           []string      `xml:"dim"`
+	Range         string        `xml:"range"`
+	Registers     []ipxRegister `xml:"register"`
+	RegisterFiles []ipxRegFile  `xml:"registerFile"`
+}
+
+type ipxRegister struct {
+	Name          string     `xml:"name"`
+	Description   string     `xml:"description"`
+	AddressOffset string     `xml:"addressOffset"`
+	Size          string     `xml:"size"`
+	Dim           []string   `xml:"dim"`
+	Access        string     `xml:"access"`
+	Reset         string     `xml:"reset>value"`
+	Fields        []ipxField `xml:"field"`
+}
+
+type ipxField struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description"`
+	BitOffset   string         `xml:"bitOffset"`
+	BitWidth    string         `xml:"bitWidth"`
+	Access      string         `xml:"access"`
+	WriteAction string         `xml:"modifiedWriteValue"`
+	ReadAction  string         `xml:"readAction"`
+	Reset       string         `xml:"resets>reset>value"`
+	Enums       []ipxEnumValue `xml:"enumeratedValues>enumeratedValue"`
+}
+
+type ipxEnumValue struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Value       string `xml:"value"`
+}
+
+type ipxParser struct {
+	errs ErrorList
+}
+
+// LoadIPXACT reads the memory maps of an IP-XACT component. Every address
+// block becomes a bank; register files are flattened into their block with
+// the file name as prefix.
+func LoadIPXACT(r io.Reader) (*RegisterMap, error) {
+	var comp ipxComponent
+	if err := xml.NewDecoder(r).Decode(&comp); err != nil {
+		return nil, fmt.Errorf("IP-XACT解析失败: %v", err)
+	}
+	p := new(ipxParser)
+	regMap := &RegisterMap{Name: comp.Name}
+	for _, mm := range comp.MemoryMaps {
+		prefix := ""
+		if len(comp.MemoryMaps) > 1 {
+			prefix = mm.Name + "."
+		}
+		if len(comp.MemoryMaps) == 1 {
+			regMap.Description = clean(mm.Description)
+		}
+		regMap.Banks = append(regMap.Banks, p.blocks(mm.Name, prefix, 0, mm.AddressBlocks, mm.Banks)...)
+	}
+	return validated(regMap, p.errs)
+}
+
+func (p *ipxParser) blocks(path, prefix string, base uint64, blocks []ipxBlock, banks []ipxMemBank) []*Bank {
+	var res []*Bank
+	for i := range blocks {
+		if bank := p.block(path, prefix, base, &blocks[i]); bank != nil {
+			res = append(res, bank)
+		}
+	}
+	for _, b := range banks {
+		addr, err := parseUint(b.BaseAddress)
+		if err != nil {
+			p.errs.Add(path+"."+b.Name, "无效baseAddress %q", b.BaseAddress)
+			continue
+		}
+		res = append(res, p.blocks(path+"."+b.Name, prefix, base+addr, b.AddressBlocks, b.Banks)...)
+	}
+	return res
+}
+
+func (p *ipxParser) block(path, prefix string, base uint64, b *ipxBlock) *Bank {
+	path += "." + b.Name
+	addr, err := parseUint(b.BaseAddress)
+	if err != nil {
+		p.errs.Add(path, "无效baseAddress %q", b.BaseAddress)
+		return nil
+	}
+	width := 32
+	if b.Width != "" {
+		if w, err := parseUint(b.Width); err != nil || w == 0 {
+			p.errs.Add(path, "无效width %q", b.Width)
+		} else {
+			width = int(w)
+		}
+	}
+	bank := &Bank{Name: prefix + b.Name, Description: clean(b.Description), Base: base + addr}
+	p.registers(bank, path, "", 0, width, b.Access, b.Registers, b.RegisterFiles)
+	return bank
+}
+
+func (p *ipxParser) registers(bank *Bank, path, prefix string, offset uint64, width int, access string, regs []ipxRegister, files []ipxRegFile) {
+	for i := range regs {
+		p.register(bank, path, prefix, offset, width, access, &regs[i])
+	}
+	for _, f := range files {
+		fpath := path + "." + f.Name
+		addr, err := parseUint(f.AddressOffset)
+		if err != nil {
+			p.errs.Add(fpath, "无效addressOffset %q", f.AddressOffset)
+			continue
+		}
+		count, ok := p.dim(fpath, f.Dim)
+		if !ok {
+			continue
+		}
+		step := uint64(0)
+		if count > 1 {
+			if step, err = parseUint(f.Range); err != nil {
+				p.errs.Add(fpath, "无效range %q", f.Range)
+				continue
+			}
+		}
+		for n := 0; n < count; n++ {
+			name := f.Name
+			if count > 1 {
+				name = fmt.Sprintf("%s%d", f.Name, n)
+			}
+			p.registers(bank, fpath, prefix+name+"_", offset+addr+uint64(n)*step, width, access, f.Registers, f.RegisterFiles)
+		}
+	}
+}
+
+// dim returns the element count of a possibly multi-dimensional array,
+// capped at 4096 elements in total.
+func (p *ipxParser) dim(path string, dims []string) (int, bool) {
+	count := 1
+	for _, d := range dims {
+		n, err := parseUint(d)
+		if err != nil || n == 0 || n > 4096 {
+			p.errs.Add(path, "无效dim %q", d)
+			return 0, false
+		}
+		count *= int(n)
+		if count > 4096 {
+			p.errs.Add(path, "数组元素超过4096个")
+			return 0, false
+		}
+	}
+	return count, true
+}
+
+func (p *ipxParser) register(bank *Bank, path, prefix string, offset uint64, width int, access string, r *ipxRegister) {
+	rpath := path + "." + r.Name
+	addr, err := parseUint(r.AddressOffset)
+	if err != nil {
+		p.errs.Add(rpath, "无效addressOffset %q", r.AddressOffset)
+		return
+	}
+	size, err := parseUint(r.Size)
+	if err != nil || size == 0 || size > 4096 {
+		p.errs.Add(rpath, "无效size %q", r.Size)
+		return
+	}
+	if r.Access != "" {
+		access = r.Access
+	}
+	reset := new(big.Int)
+	if r.Reset != "" {
+		if reset, err = ParseNumber(r.Reset); err != nil {
+			p.errs.Add(rpath, "无效reset %q", r.Reset)
+			reset = new(big.Int)
+		}
+	}
+	var fields []Field
+	for i := range r.Fields {
+		f, ok := p.field(rpath, int(size), access, &r.Fields[i])
+		if !ok {
+			continue
+		}
+		// 1685-2014 moved reset values from the register to its fields
+		if fr := r.Fields[i].Reset; fr != "" && r.Reset == "" {
+			if num, err := ParseNumber(fr); err != nil {
+				p.errs.Add(rpath+"."+f.Name, "无效reset %q", fr)
+			} else {
+				num.And(num, Mask(f.Width()))
+				reset.Or(reset, num.Lsh(num, uint(f.Lsb)))
+			}
+		}
+		fields = append(fields, f)
+	}
+	count, ok := p.dim(rpath, r.Dim)
+	if !ok {
+		return
+	}
+	step := (size + uint64(width) - 1) / uint64(width) * uint64(width) / 8
+	for n := 0; n < count; n++ {
+		name := r.Name
+		if count > 1 {
+			name = fmt.Sprintf("%s%d", r.Name, n)
+		}
+		bank.Registers = append(bank.Registers, &Layout{
+			Name:        prefix + name,
+			Description: clean(r.Description),
+			Width:       int(size),
+			Offset:      offset + addr + uint64(n)*step,
+			Access:      access,
+			Reset:       new(big.Int).And(reset, Mask(int(size))),
+			Fields:      fields,
+		})
+	}
+}
+
+func (p *ipxParser) field(path string, size int, access string, f *ipxField) (Field, bool) {
+	fpath := path + "." + f.Name
+	lsb, err := parseUint(f.BitOffset)
+	if err != nil {
+		p.errs.Add(fpath, "无效bitOffset %q", f.BitOffset)
+		return Field{}, false
+	}
+	width, err := parseUint(f.BitWidth)
+	if err != nil || width == 0 {
+		p.errs.Add(fpath, "无效bitWidth %q", f.BitWidth)
+		return Field{}, false
+	}
+	// compared before adding, the file may hold any 64-bit numbers
+	if lsb >= uint64(size) || width > uint64(size)-lsb {
+		p.errs.Add(fpath, "位域(bitOffset %d, bitWidth %d)超出寄存器宽度%d", lsb, width, size)
+		return Field{}, false
+	}
+	msb := lsb + width - 1
+	if f.Access != "" {
+		access = f.Access
+	}
+	field := Field{
+		Name:        f.Name,
+		Msb:         int(msb),
+		Lsb:         int(lsb),
+		Description: clean(f.Description),
+		Access:      access,
+		WriteAction: f.WriteAction,
+		ReadAction:  f.ReadAction,
+	}
+	for _, v := range f.Enums {
+		addEnum(&p.errs, fpath, &field, v.Name, v.Value, v.Description)
+	}
+	return field, true
+}
@@ -0,0 +1,102 @@
+package regmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+const ipxactSample = `<?xml version="1.0" encoding="UTF-8"?>
+<ipxact:component xmlns:ipxact="http://www.accellera.org/XMLSchema/IPXACT/1685-2014">
+  <ipxact:name>uart</ipxact:name>
+  <ipxact:memoryMaps>
+    <ipxact:memoryMap>
+      <ipxact:name>regs</ipxact:name>
+      <ipxact:addressBlock>
+        <ipxact:name>UART</ipxact:name>
+        <ipxact:baseAddress>0x1000</ipxact:baseAddress>
+        <ipxact:width>32</ipxact:width>
+        <ipxact:access>read-write</ipxact:access>
+        <ipxact:register>
+          <ipxact:name>CTRL</ipxact:name>
+          <ipxact:addressOffset>0x4</ipxact:addressOffset>
+          <ipxact:size>32</ipxact:size>
+          <ipxact:field>
+            <ipxact:name>EN</ipxact:name>
+            <ipxact:bitOffset>0</ipxact:bitOffset>
+            <ipxact:resets><ipxact:reset><ipxact:value>1</ipxact:value></ipxact:reset></ipxact:resets>
+            <ipxact:bitWidth>1</ipxact:bitWidth>
+          </ipxact:field>
+          <ipxact:field>
+            <ipxact:name>MODE</ipxact:name>
+            <ipxact:bitOffset>4</ipxact:bitOffset>
+            <ipxact:resets><ipxact:reset><ipxact:value>'h2</ipxact:value></ipxact:reset></ipxact:resets>
+            <ipxact:bitWidth>2</ipxact:bitWidth>
+            <ipxact:access>read-only</ipxact:access>
+            <ipxact:enumeratedValues>
+              <ipxact:enumeratedValue><ipxact:name>IDLE</ipxact:name><ipxact:value>0</ipxact:value></ipxact:enumeratedValue>
+              <ipxact:enumeratedValue><ipxact:name>RUN</ipxact:name><ipxact:value>2</ipxact:value></ipxact:enumeratedValue>
+            </ipxact:enumeratedValues>
+          </ipxact:field>
+        </ipxact:register>
+        <ipxact:register>
+          <ipxact:name>DATA</ipxact:name>
+          <ipxact:addressOffset>0x10</ipxact:addressOffset>
+          <ipxact:size>8</ipxact:size>
+          <ipxact:dim>2</ipxact:dim>
+        </ipxact:register>
+      </ipxact:addressBlock>
+    </ipxact:memoryMap>
+  </ipxact:memoryMaps>
+</ipxact:component>`
+
+func TestLoadIPXACT(t *testing.T) {
+	m, err := LoadIPXACT(strings.NewReader(ipxactSample))
+	if msg := loaded(t, m, err); msg != "" {
+		t.Fatalf("errors: %s", msg)
+	}
+	if m.Name != "uart" || len(m.Banks) != 1 || m.Banks[0].Base != 0x1000 {
+		t.Fatalf("map %s with %d banks", m.Name, len(m.Banks))
+	}
+	ctrl := layout(t, m, "UART", "CTRL")
+	if ctrl.Offset != 4 || ctrl.Reset.Int64() != 0x21 {
+		t.Errorf("CTRL at 0x%x reset 0x%x", ctrl.Offset, ctrl.Reset)
+	}
+	mode := ctrl.Field("MODE")
+	if mode == nil || mode.Msb != 5 || mode.Lsb != 4 || mode.Access != "read-only" || len(mode.Enums) != 2 {
+		t.Errorf("MODE = %+v", mode)
+	}
+	if en := ctrl.Field("EN"); en == nil || en.Access != "read-write" {
+		t.Errorf("EN = %+v", en)
+	}
+	for i, name := range []string{"DATA0", "DATA1"} {
+		if l := layout(t, m, "UART", name); l.Offset != uint64(0x10+4*i) || l.Width != 8 {
+			t.Errorf("%s at 0x%x width %d", name, l.Offset, l.Width)
+		}
+	}
+}
+
+func TestLoadIPXACTLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		reg  string
+		want string
+	}{
+		{"size", `<size>0x10000000</size>`, "无效size"},
+		{"zero size", `<size>0</size>`, "无效size"},
+		{"bitOffset", `<size>32</size><field><name>F</name><bitOffset>0xffffffffffffffff</bitOffset><bitWidth>2</bitWidth></field>`, "超出寄存器宽度"},
+		{"bitWidth", `<size>32</size><field><name>F</name><bitOffset>4</bitOffset><bitWidth>0xffffffffffffffff</bitWidth></field>`, "超出寄存器宽度"},
+		{"zero bitWidth", `<size>32</size><field><name>F</name><bitOffset>4</bitOffset><bitWidth>0</bitWidth></field>`, "无效bitWidth"},
+		{"dim", `<dim>4097</dim><size>32</size>`, "无效dim"},
+		{"dim product", `<dim>4096</dim><dim>4096</dim><dim>4096</dim><size>32</size>`, "数组元素超过4096个"},
+	}
+	for _, tt := range tests {
+		src := `<component><name>c</name><memoryMaps><memoryMap><name>m</name><addressBlock>
+			<name>B</name><baseAddress>0</baseAddress><width>32</width>
+			<register><name>R</name><addressOffset>0</addressOffset>` + tt.reg + `</register>
+			</addressBlock></memoryMap></memoryMaps></component>`
+		m, err := LoadIPXACT(strings.NewReader(src))
+		if msg := loaded(t, m, err); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: errors %q, want %q", tt.name, msg, tt.want)
+		}
+	}
+}
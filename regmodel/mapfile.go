@@ -0,0 +1,308 @@
+package regmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Native register map format, the same structure in JSON and YAML:
+//
+//	name: DEV
+//	banks:
+//	  - name: TIM1
+//	    base: 0x40000000
+//	    registers:
+//	      - name: CR1
+//	        offset: 0x0
+//	        width: 32
+//	        reset: 0x0
+//	        fields:
+//	          - name: MODE
+//	            bits: "5:4"
+//	            access: read-write
+//	            enums:
+//	              - {name: FAST, value: 1}
+//
+// Numbers may be written as plain integers or as strings in any notation
+// accepted by ParseNumber.
+type mapFile struct {
+	Name        string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Banks       []bankFile `json:"banks" yaml:"banks"`
+}
+
+type bankFile struct {
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	Base        mapNumber      `json:"base,omitempty" yaml:"base,omitempty"`
+	Registers   []registerFile `json:"registers" yaml:"registers"`
+}
+
+type registerFile struct {
+	Name        string      `json:"name" yaml:"name"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Offset      mapNumber   `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Width       int         `json:"width" yaml:"width"`
+	Reset       mapNumber   `json:"reset,omitempty" yaml:"reset,omitempty"`
+	Access      string      `json:"access,omitempty" yaml:"access,omitempty"`
+	Fields      []fieldFile `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+type fieldFile struct {
+	Name        string     `json:"name" yaml:"name"`
+	Bits        string     `json:"bits" yaml:"bits"`
+	Description string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Access      string     `json:"access,omitempty" yaml:"access,omitempty"`
+	WriteAction string     `json:"write_action,omitempty" yaml:"write_action,omitempty"`
+	ReadAction  string     `json:"read_action,omitempty" yaml:"read_action,omitempty"`
+	Hardware    string     `json:"hw,omitempty" yaml:"hw,omitempty"`
+	Enums       []enumFile `json:"enums,omitempty" yaml:"enums,omitempty"`
+}
+
+type enumFile struct {
+	Name        string    `json:"name" yaml:"name"`
+	Value       mapNumber `json:"value" yaml:"value"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// mapNumber keeps the source text of a number, converted with ParseNumber.
+type mapNumber string
+
+// MarshalYAML writes the number unquoted.
+func (n mapNumber) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: string(n)}, nil
+}
+
+func hexNumber(n *big.Int) mapNumber {
+	if n == nil || n.Sign() == 0 {
+		return ""
+	}
+	return mapNumber("0x" + n.Text(16))
+}
+
+// LoadMap reads the native format. JSON is read by the YAML decoder, which
+// accepts it as a subset. Conversion problems and the findings of Validate
+// are returned together as an ErrorList.
+func LoadMap(r io.Reader) (*RegisterMap, error) {
+	var file mapFile
+	if err := yaml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("寄存器表解析失败: %v", err)
+	}
+	return file.regMap()
+}
+
+func (file *mapFile) regMap() (*RegisterMap, error) {
+	var errs ErrorList
+	regMap := &RegisterMap{Name: file.Name, Description: file.Description}
+	for _, b := range file.Banks {
+		bank := &Bank{Name: b.Name, Description: b.Description}
+		bank.Base = fileUint(&errs, b.Name, "base", b.Base)
+		for _, r := range b.Registers {
+			bank.Registers = append(bank.Registers, r.layout(&errs, b.Name+"."+r.Name))
+		}
+		regMap.Banks = append(regMap.Banks, bank)
+	}
+	return validated(regMap, errs)
+}
+
+func (r *registerFile) layout(errs *ErrorList, path string) *Layout {
+	layout := &Layout{
+		Name:        r.Name,
+		Description: r.Description,
+		Width:       r.Width,
+		Offset:      fileUint(errs, path, "offset", r.Offset),
+		Access:      r.Access,
+		Reset:       new(big.Int),
+	}
+	if r.Reset != "" {
+		if reset, err := ParseNumber(string(r.Reset)); err != nil {
+			errs.Add(path, "无效reset %q", r.Reset)
+		} else {
+			layout.Reset = reset
+		}
+	}
+	for _, f := range r.Fields {
+		fpath := path + "." + f.Name
+		// checked against the register width by Validate
+		msb, lsb, err := ParseBitRange(f.Bits, maxFileBits)
+		if err != nil {
+			errs.Add(fpath, "无效bits %q", f.Bits)
+			continue
+		}
+		field := Field{
+			Name:        f.Name,
+			Msb:         msb,
+			Lsb:         lsb,
+			Description: f.Description,
+			Access:      f.Access,
+			WriteAction: f.WriteAction,
+			ReadAction:  f.ReadAction,
+			Hardware:    f.Hardware,
+		}
+		for _, e := range f.Enums {
+			num, err := ParseNumber(string(e.Value))
+			if err != nil {
+				errs.Add(fpath, "枚举%s: %v", e.Name, err)
+				continue
+			}
+			field.Enums = append(field.Enums, Enum{Name: e.Name, Value: num, Description: e.Description})
+		}
+		layout.Fields = append(layout.Fields, field)
+	}
+	return layout
+}
+
+const maxFileBits = 1 << 16
+
+func fileUint(errs *ErrorList, path, name string, n mapNumber) uint64 {
+	if n == "" {
+		return 0
+	}
+	num, err := parseUint(string(n))
+	if err != nil {
+		errs.Add(path, "无效%s %q", name, n)
+	}
+	return num
+}
+
+func (m *RegisterMap) file() *mapFile {
+	file := &mapFile{Name: m.Name, Description: m.Description}
+	for _, b := range m.Banks {
+		bank := bankFile{Name: b.Name, Description: b.Description, Base: mapNumber(fmt.Sprintf("0x%X", b.Base))}
+		for _, l := range b.Registers {
+			bank.Registers = append(bank.Registers, layoutFile(l))
+		}
+		file.Banks = append(file.Banks, bank)
+	}
+	return file
+}
+
+func layoutFile(l *Layout) registerFile {
+	reg := registerFile{
+		Name:        l.Name,
+		Description: l.Description,
+		Offset:      mapNumber(fmt.Sprintf("0x%X", l.Offset)),
+		Width:       l.Width,
+		Reset:       hexNumber(l.Reset),
+		Access:      l.Access,
+	}
+	for _, f := range l.Fields {
+		field := fieldFile{
+			Name:        f.Name,
+			Bits:        f.Range(),
+			Description: f.Description,
+			Access:      f.Access,
+			WriteAction: f.WriteAction,
+			ReadAction:  f.ReadAction,
+			Hardware:    f.Hardware,
+		}
+		for _, e := range f.Enums {
+			field.Enums = append(field.Enums, enumFile{Name: e.Name, Value: mapNumber(e.Value.String()), Description: e.Description})
+		}
+		reg.Fields = append(reg.Fields, field)
+	}
+	return reg
+}
+
+func (m *RegisterMap) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m.file())
+}
+
+func (m *RegisterMap) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(m.file()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Validate reports duplicate names, widths beyond 4096 bits, inverted bit
+// ranges, fields outside their register, fields that overlap and enum values
+// that do not fit their field. All loaders run it on what they read.
+func (m *RegisterMap) Validate() error {
+	var errs ErrorList
+	banks := make(map[string]bool)
+	for _, b := range m.Banks {
+		if banks[b.Name] {
+			errs.Add(b.Name, "寄存器组名称重复")
+		}
+		banks[b.Name] = true
+		regs := make(map[string]bool)
+		for _, l := range b.Registers {
+			path := b.Name + "." + l.Name
+			if regs[l.Name] {
+				errs.Add(path, "寄存器名称重复")
+			}
+			regs[l.Name] = true
+			validateLayout(&errs, path, l)
+		}
+	}
+	return errs.Err()
+}
+
+func validateLayout(errs *ErrorList, path string, l *Layout) {
+	// the importers cap register widths at 4096 bits, so does the native format
+	if l.Width < 1 || l.Width > 4096 {
+		errs.Add(path, "无效位宽 %d", l.Width)
+		return
+	}
+	if l.Reset != nil && l.Reset.BitLen() > l.Width {
+		errs.Add(path, "复位值0x%s超出位宽%d", l.Reset.Text(16), l.Width)
+	}
+	names := make(map[string]bool)
+	order := make([]*Field, 0, len(l.Fields))
+	for i := range l.Fields {
+		f := &l.Fields[i]
+		fpath := path + "." + f.Name
+		if names[f.Name] {
+			errs.Add(fpath, "位域名称重复")
+		}
+		names[f.Name] = true
+		// left out of the overlap check, Mask would fail on them
+		if f.Lsb < 0 || f.Msb < f.Lsb {
+			errs.Add(fpath, "无效位范围[%d:%d]", f.Msb, f.Lsb)
+			continue
+		}
+		if f.Msb >= l.Width {
+			errs.Add(fpath, "位范围[%s]超出寄存器位宽%d", f.Range(), l.Width)
+		}
+		enums := make(map[string]bool)
+		values := make(map[string]string)
+		for _, e := range f.Enums {
+			if enums[e.Name] {
+				errs.Add(fpath, "枚举名称重复 %s", e.Name)
+			}
+			enums[e.Name] = true
+			if other, ok := values[e.Value.String()]; ok {
+				errs.Add(fpath, "枚举%s与%s的值%s重复", e.Name, other, e.Value)
+			} else {
+				values[e.Value.String()] = e.Name
+			}
+			if e.Value.BitLen() > f.Width() {
+				errs.Add(fpath, "枚举%s: 值%s超出位域宽度%d", e.Name, e.Value, f.Width())
+			}
+		}
+		order = append(order, f)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Lsb < order[j].Lsb
+	})
+	// compare each field with the one reaching highest among those below it
+	for i := 1; i < len(order); i++ {
+		prev, f := order[i-1], order[i]
+		if f.Lsb <= prev.Msb {
+			errs.Add(path+"."+f.Name, "与%s[%s]重叠", prev.Name, prev.Range())
+		}
+		if prev.Msb > f.Msb {
+			order[i] = prev
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package regmodel
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mapSample = `
+name: DEV
+banks:
+  - name: TIM1
+    base: 64k
+    registers:
+      - name: CR1
+        offset: 0x4
+        width: 32
+        reset: 0x81
+        fields:
+          - name: MODE
+            bits: "5:4"
+            access: read-write
+            enums:
+              - {name: SLOW, value: 0}
+              - {name: FAST, value: 0b10}
+          - name: EN
+            bits: "0"
+            write_action: oneToClear
+`
+
+func TestLoadMap(t *testing.T) {
+	m, err := LoadMap(strings.NewReader(mapSample))
+	if msg := loaded(t, m, err); msg != "" {
+		t.Fatalf("errors: %s", msg)
+	}
+	if m.Banks[0].Base != 0x10000 {
+		t.Errorf("base 0x%x", m.Banks[0].Base)
+	}
+	cr1 := layout(t, m, "TIM1", "CR1")
+	if cr1.Offset != 4 || cr1.Reset.Int64() != 0x81 {
+		t.Errorf("CR1 at 0x%x reset 0x%x", cr1.Offset, cr1.Reset)
+	}
+	if mode := cr1.Field("MODE"); mode == nil || mode.Msb != 5 || mode.Lsb != 4 || mode.Enums[1].Value.Int64() != 2 {
+		t.Errorf("MODE = %+v", mode)
+	}
+	if en := cr1.Field("EN"); en == nil || en.WriteAction != "oneToClear" {
+		t.Errorf("EN = %+v", en)
+	}
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	sources := map[string]func() (*RegisterMap, error){
+		"map": func() (*RegisterMap, error) { return LoadMap(strings.NewReader(mapSample)) },
+		"svd": func() (*RegisterMap, error) { return LoadSVD(strings.NewReader(svdSample)) },
+		"rdl": func() (*RegisterMap, error) { return LoadRDL(strings.NewReader(rdlSample)) },
+	}
+	for name, load := range sources {
+		m, err := load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for format, write := range map[string]func(*RegisterMap, *bytes.Buffer) error{
+			"json": func(m *RegisterMap, buf *bytes.Buffer) error { return m.WriteJSON(buf) },
+			"yaml": func(m *RegisterMap, buf *bytes.Buffer) error { return m.WriteYAML(buf) },
+		} {
+			var buf bytes.Buffer
+			if err := write(m, &buf); err != nil {
+				t.Fatalf("%s %s: %v", name, format, err)
+			}
+			got, err := LoadMap(&buf)
+			if err != nil {
+				t.Fatalf("%s %s: %v", name, format, err)
+			}
+			if !reflect.DeepEqual(got, m) {
+				t.Errorf("%s %s: round trip differs", name, format)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		reg  string
+		want string
+	}{
+		{"overlap", `fields: [{name: A, bits: "3:0"}, {name: B, bits: "4:2"}]`, "TIM1.R.B: 与A[3:0]重叠"},
+		{"range", `fields: [{name: A, bits: "40:32"}]`, "位范围[40:32]超出寄存器位宽32"},
+		{"field name", `fields: [{name: A, bits: "0"}, {name: A, bits: "1"}]`, "位域名称重复"},
+		{"reset", `reset: 0x1_0000_0000`, "复位值0x100000000超出位宽32"},
+		{"enum name", `fields: [{name: A, bits: "1:0", enums: [{name: X, value: 0}, {name: X, value: 1}]}]`, "枚举名称重复 X"},
+		{"enum value", `fields: [{name: A, bits: "1:0", enums: [{name: X, value: 1}, {name: Y, value: 1}]}]`, "枚举Y与X的值1重复"},
+		{"enum width", `fields: [{name: A, bits: "1:0", enums: [{name: X, value: 4}]}]`, "枚举X: 值4超出位域宽度2"},
+		{"bits", `fields: [{name: A, bits: "x"}]`, "无效bits"},
+	}
+	for _, tt := range tests {
+		src := "banks: [{name: TIM1, registers: [{name: R, width: 32, " + tt.reg + "}]}]"
+		m, err := LoadMap(strings.NewReader(src))
+		if msg := loaded(t, m, err); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: errors %q, want %q", tt.name, msg, tt.want)
+		}
+	}
+	for _, width := range []string{"0", "4097", "2000000000"} {
+		src := "banks: [{name: TIM1, registers: [{name: R, width: " + width + "}]}]"
+		m, err := LoadMap(strings.NewReader(src))
+		if msg := loaded(t, m, err); !strings.Contains(msg, "无效位宽 "+width) {
+			t.Errorf("width %s: errors %q", width, msg)
+		}
+	}
+	bank := &Bank{Name: "B", Registers: []*Layout{{Name: "R", Width: 32, Fields: []Field{
+		{Name: "INV", Msb: 3, Lsb: 4},
+		{Name: "NEG", Msb: 2, Lsb: -1},
+		{Name: "OK", Msb: 7, Lsb: 0},
+	}}}}
+	m := &RegisterMap{Banks: []*Bank{{Name: "A"}, {Name: "A"}, bank}}
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil")
+	}
+	for _, want := range []string{"寄存器组名称重复", "B.R.INV: 无效位范围[3:4]", "B.R.NEG: 无效位范围[2:-1]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "重叠") {
+		t.Errorf("invalid fields checked for overlap: %v", err)
+	}
+}
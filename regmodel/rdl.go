@@ -0,0 +1,784 @@
+package regmodel
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strings"
+)
+
+// SystemRDL subset: addrmap, regfile, reg, field and enum definitions (named
+// or anonymous), instances with [N] / [msb:lsb] / [width], = reset, @ address
+// and += stride, property assignments and defaults. Expressions, parameters
+// and dynamic assignments are not supported.
+
+var (
+	rdlSwAccess = map[string]string{
+		"rw": "read-write", "wr": "read-write", "r": "read-only", "w": "write-only",
+		"rw1": "read-writeOnce", "w1": "writeOnce", "na": "RSV",
+	}
+	rdlOnWrite = map[string]string{
+		"woset": "oneToSet", "woclr": "oneToClear", "wot": "oneToToggle",
+		"wzs": "zeroToSet", "wzc": "zeroToClear", "wzt": "zeroToToggle",
+		"wclr": "clear", "wset": "set",
+	}
+	rdlOnRead = map[string]string{
+		"rclr": "clear", "rset": "set",
+	}
+)
+
+type rdlPos struct {
+	file      string
+	line, col int
+}
+
+func (p rdlPos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.file, p.line, p.col)
+}
+
+// token kinds: 'i' identifier, 'n' number, 's' string, 'p' punctuation and
+// 0 for the end of the file
+type rdlToken struct {
+	kind byte
+	text string
+	pos  rdlPos
+}
+
+func (t rdlToken) String() string {
+	if t.kind == 0 {
+		return "文件结尾"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func isRDLIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func rdlLex(file, src string) ([]rdlToken, error) {
+	var toks []rdlToken
+	i, line, col := 0, 1, 1
+	advance := func(n int) {
+		for ; n > 0 && i < len(src); n-- {
+			if src[i] == '\n' {
+				line, col = line+1, 1
+			} else if src[i]&0xC0 != 0x80 {
+				col++
+			}
+			i++
+		}
+	}
+	for i < len(src) {
+		c := src[i]
+		pos := rdlPos{file, line, col}
+		rest := src[i:]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			advance(1)
+		case strings.HasPrefix(rest, "//"):
+			for i < len(src) && src[i] != '\n' {
+				advance(1)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%s: 注释未结束", pos)
+			}
+			advance(end + 4)
+		case c == '"':
+			j := 1
+			for j < len(rest) && rest[j] != '"' {
+				if rest[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(rest) {
+				return nil, fmt.Errorf("%s: 字符串未结束", pos)
+			}
+			toks = append(toks, rdlToken{'s', strings.ReplaceAll(rest[1:j], `\"`, `"`), pos})
+			advance(j + 1)
+		case c >= '0' && c <= '9' || c == '\'':
+			j := 1
+			for j < len(rest) && (isRDLIdent(rest[j]) || rest[j] == '\'') {
+				j++
+			}
+			toks = append(toks, rdlToken{'n', rest[:j], pos})
+			advance(j)
+		case isRDLIdent(c):
+			j := 1
+			for j < len(rest) && isRDLIdent(rest[j]) {
+				j++
+			}
+			toks = append(toks, rdlToken{'i', rest[:j], pos})
+			advance(j)
+		case strings.HasPrefix(rest, "+=") || strings.HasPrefix(rest, "%=") || strings.HasPrefix(rest, "->"):
+			toks = append(toks, rdlToken{'p', rest[:2], pos})
+			advance(2)
+		case strings.IndexByte("{}[];:=@,", c) >= 0:
+			toks = append(toks, rdlToken{'p', rest[:1], pos})
+			advance(1)
+		default:
+			return nil, fmt.Errorf("%s: 非法字符 %q", pos, rest[:1])
+		}
+	}
+	return append(toks, rdlToken{pos: rdlPos{file, line, col}}), nil
+}
+
+type rdlComp struct {
+	kind     string
+	name     string
+	pos      rdlPos
+	parent   *rdlComp
+	props    map[string]rdlToken
+	defaults map[string]rdlToken
+	types    map[string]*rdlComp
+	defs     []*rdlComp
+	enums    map[string]*rdlEnum
+	insts    []*rdlInst
+	used     bool
+}
+
+func newRDLComp(kind string, pos rdlPos, parent *rdlComp) *rdlComp {
+	return &rdlComp{
+		kind:     kind,
+		pos:      pos,
+		parent:   parent,
+		props:    make(map[string]rdlToken),
+		defaults: make(map[string]rdlToken),
+		types:    make(map[string]*rdlComp),
+		enums:    make(map[string]*rdlEnum),
+	}
+}
+
+func (c *rdlComp) lookup(name string) *rdlComp {
+	for ; c != nil; c = c.parent {
+		if t, ok := c.types[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+func (c *rdlComp) enum(name string) *rdlEnum {
+	for ; c != nil; c = c.parent {
+		if e, ok := c.enums[name]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// prop returns an assigned property, falling back to the defaults of the
+// enclosing scopes.
+func (c *rdlComp) prop(name string) (rdlToken, bool) {
+	if v, ok := c.props[name]; ok {
+		return v, true
+	}
+	for s := c.parent; s != nil; s = s.parent {
+		if v, ok := s.defaults[name]; ok {
+			return v, true
+		}
+	}
+	return rdlToken{}, false
+}
+
+type rdlInst struct {
+	comp   *rdlComp
+	name   string
+	pos    rdlPos
+	ranges [][]rdlToken
+	reset  *rdlToken
+	addr   *rdlToken
+	stride *rdlToken
+}
+
+type rdlEnum struct {
+	name   string
+	values []rdlEnumValue
+}
+
+type rdlEnumValue struct {
+	name  string
+	value *rdlToken
+	desc  string
+	pos   rdlPos
+}
+
+type rdlParser struct {
+	toks []rdlToken
+	i    int
+	errs ErrorList
+}
+
+func (p *rdlParser) peek() rdlToken {
+	return p.toks[p.i]
+}
+
+func (p *rdlParser) next() rdlToken {
+	t := p.toks[p.i]
+	if t.kind != 0 {
+		p.i++
+	}
+	return t
+}
+
+func (p *rdlParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == 'p' || t.kind == 'i') && t.text == text
+}
+
+func (p *rdlParser) errorf(t rdlToken, format string, a ...interface{}) error {
+	return fmt.Errorf("%s: %s", t.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *rdlParser) expect(text string) error {
+	if t := p.next(); (t.kind != 'p' && t.kind != 'i') || t.text != text {
+		return p.errorf(t, "应为%q, 得到%s", text, t)
+	}
+	return nil
+}
+
+func (p *rdlParser) ident() (rdlToken, error) {
+	t := p.next()
+	if t.kind != 'i' {
+		return t, p.errorf(t, "应为名称, 得到%s", t)
+	}
+	return t, nil
+}
+
+func (p *rdlParser) number() (*rdlToken, error) {
+	t := p.next()
+	if t.kind != 'n' {
+		return nil, p.errorf(t, "应为数值, 得到%s", t)
+	}
+	return &t, nil
+}
+
+func (p *rdlParser) body(c *rdlComp) error {
+	for !p.is("}") {
+		if p.peek().kind == 0 {
+			return p.errorf(p.peek(), "缺少 }")
+		}
+		if err := p.item(c); err != nil {
+			return err
+		}
+	}
+	p.next()
+	return nil
+}
+
+func (p *rdlParser) item(c *rdlComp) error {
+	t := p.peek()
+	if t.kind != 'i' {
+		return p.errorf(t, "意外的%s", t)
+	}
+	switch t.text {
+	case "external", "internal":
+		p.next()
+		return p.item(c)
+	case "addrmap", "regfile", "reg", "field":
+		return p.definition(c)
+	case "enum":
+		return p.enumDef(c)
+	case "default":
+		p.next()
+		return p.property(c.defaults)
+	}
+	switch after := p.toks[p.i+1]; {
+	case after.kind == 'i':
+		p.next()
+		typ := c.lookup(t.text)
+		if typ == nil {
+			return p.errorf(t, "未定义的类型 %s", t.text)
+		}
+		return p.instances(c, typ)
+	case after.kind == 'p' && after.text == "->":
+		p.errs.Add(t.pos.String(), "不支持动态属性赋值, 已忽略")
+		for !p.is(";") && p.peek().kind != 0 {
+			p.next()
+		}
+		return p.expect(";")
+	}
+	return p.property(c.props)
+}
+
+func (p *rdlParser) definition(c *rdlComp) error {
+	t := p.next()
+	def := newRDLComp(t.text, t.pos, c)
+	if p.peek().kind == 'i' {
+		def.name = p.next().text
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	if err := p.body(def); err != nil {
+		return err
+	}
+	if def.name != "" {
+		c.types[def.name] = def
+		c.defs = append(c.defs, def)
+	}
+	if p.is(";") {
+		p.next()
+		if def.name == "" {
+			return p.errorf(t, "匿名%s未实例化", t.text)
+		}
+		return nil
+	}
+	return p.instances(c, def)
+}
+
+func (p *rdlParser) instances(c *rdlComp, typ *rdlComp) error {
+	for {
+		t, err := p.ident()
+		if err != nil {
+			return err
+		}
+		inst := &rdlInst{comp: typ, name: t.text, pos: t.pos}
+		for p.is("[") {
+			p.next()
+			n, err := p.number()
+			if err != nil {
+				return err
+			}
+			r := []rdlToken{*n}
+			if p.is(":") {
+				p.next()
+				if n, err = p.number(); err != nil {
+					return err
+				}
+				r = append(r, *n)
+			}
+			if err := p.expect("]"); err != nil {
+				return err
+			}
+			inst.ranges = append(inst.ranges, r)
+		}
+		for _, op := range []string{"=", "@", "+=", "%="} {
+			if !p.is(op) {
+				continue
+			}
+			p.next()
+			n, err := p.number()
+			if err != nil {
+				return err
+			}
+			switch op {
+			case "=":
+				inst.reset = n
+			case "@":
+				inst.addr = n
+			case "+=":
+				inst.stride = n
+			}
+		}
+		typ.used = true
+		c.insts = append(c.insts, inst)
+		if !p.is(",") {
+			return p.expect(";")
+		}
+		p.next()
+	}
+}
+
+func (p *rdlParser) property(into map[string]rdlToken) error {
+	t, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if p.is(";") {
+		p.next()
+		into[t.text] = rdlToken{kind: 'i', text: "true", pos: t.pos}
+		return nil
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	v := p.next()
+	if v.kind == 0 || v.kind == 'p' {
+		return p.errorf(v, "应为属性值, 得到%s", v)
+	}
+	into[t.text] = v
+	return p.expect(";")
+}
+
+func (p *rdlParser) enumDef(c *rdlComp) error {
+	p.next()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	enum := &rdlEnum{name: name.text}
+	for !p.is("}") {
+		t, err := p.ident()
+		if err != nil {
+			return err
+		}
+		v := rdlEnumValue{name: t.text, pos: t.pos}
+		if p.is("=") {
+			p.next()
+			if v.value, err = p.number(); err != nil {
+				return err
+			}
+		}
+		if p.is("{") {
+			p.next()
+			props := make(map[string]rdlToken)
+			for !p.is("}") {
+				if err := p.property(props); err != nil {
+					return err
+				}
+			}
+			p.next()
+			v.desc = props["desc"].text
+			if v.desc == "" {
+				v.desc = props["name"].text
+			}
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+		enum.values = append(enum.values, v)
+	}
+	p.next()
+	c.enums[enum.name] = enum
+	return p.expect(";")
+}
+
+type rdlElab struct {
+	errs   *ErrorList
+	regMap *RegisterMap
+}
+
+func (e *rdlElab) num(t *rdlToken) (*big.Int, bool) {
+	num, err := ParseNumber(t.text)
+	if err != nil {
+		e.errs.Add(t.pos.String(), "无效数值 %q", t.text)
+		return nil, false
+	}
+	return num, true
+}
+
+func (e *rdlElab) uint(t *rdlToken, def uint64) uint64 {
+	if t == nil {
+		return def
+	}
+	num, ok := e.num(t)
+	if !ok {
+		return def
+	}
+	if !num.IsUint64() {
+		e.errs.Add(t.pos.String(), "数值过大 %q", t.text)
+		return def
+	}
+	return num.Uint64()
+}
+
+func (e *rdlElab) str(c *rdlComp, names ...string) string {
+	for _, name := range names {
+		if v, ok := c.prop(name); ok && v.kind == 's' {
+			return clean(v.text)
+		}
+	}
+	return ""
+}
+
+// fieldWidth reads the width of a field placed at lsb, from fieldwidth or
+// [N]. It is checked before it is added to lsb, the file may hold any 64-bit
+// number.
+func (e *rdlElab) fieldWidth(t *rdlToken, lsb uint64, width int) (uint64, bool) {
+	num, ok := e.num(t)
+	if !ok {
+		return 0, false
+	}
+	if num.Sign() == 0 {
+		e.errs.Add(t.pos.String(), "无效位域宽度 %q", t.text)
+		return 0, false
+	}
+	if lsb >= uint64(width) || !num.IsUint64() || num.Uint64() > uint64(width)-lsb {
+		e.errs.Add(t.pos.String(), "位域宽度%s超出寄存器宽度%d", t.text, width)
+		return 0, false
+	}
+	return num.Uint64(), true
+}
+
+// count is the number of elements of an array instance; fields use their
+// brackets for the bit range instead.
+func (e *rdlElab) count(inst *rdlInst) int {
+	count := 1
+	for _, r := range inst.ranges {
+		if len(r) != 1 {
+			e.errs.Add(r[0].pos.String(), "数组维度应为[N]")
+			return 0
+		}
+		n := e.uint(&r[0], 0)
+		if n == 0 || n > 4096 {
+			e.errs.Add(r[0].pos.String(), "无效数组大小 %q", r[0].text)
+			return 0
+		}
+		// each dimension is capped, so the product cannot overflow first
+		if count *= int(n); count > 4096 {
+			e.errs.Add(r[0].pos.String(), "数组元素超过4096个")
+			return 0
+		}
+	}
+	return count
+}
+
+func (e *rdlElab) addrmap(c *rdlComp, name string, base uint64) uint64 {
+	bank := &Bank{Name: name, Description: e.str(c, "desc", "name"), Base: base}
+	e.regMap.Banks = append(e.regMap.Banks, bank)
+	return e.block(c, bank, name, "", 0)
+}
+
+// block places the instances of an addrmap or regfile at start (relative to
+// the bank) and returns the space they take.
+func (e *rdlElab) block(c *rdlComp, bank *Bank, path, prefix string, start uint64) uint64 {
+	cursor, end := start, start
+	for _, inst := range c.insts {
+		kind := inst.comp.kind
+		if kind != "reg" && kind != "regfile" && kind != "addrmap" {
+			e.errs.Add(inst.pos.String(), "%s内不能实例化%s", c.kind, kind)
+			continue
+		}
+		count := e.count(inst)
+		addr := cursor
+		if inst.addr != nil {
+			addr = start + e.uint(inst.addr, 0)
+		} else if kind == "reg" {
+			size := e.regSize(inst.comp)
+			addr = (cursor + size - 1) / size * size
+		}
+		stride := e.uint(inst.stride, 0)
+		var size uint64
+		for n := 0; n < count; n++ {
+			name := inst.name
+			if count > 1 {
+				name = fmt.Sprintf("%s[%d]", inst.name, n)
+			}
+			// without += the elements are packed by the size of the first
+			if inst.stride == nil {
+				stride = size
+			}
+			at := addr + uint64(n)*stride
+			switch kind {
+			case "reg":
+				size = e.reg(inst.comp, bank, prefix+name, at)
+			case "regfile":
+				size = e.block(inst.comp, bank, path+"."+name, prefix+name+"_", at)
+			case "addrmap":
+				size = e.addrmap(inst.comp, path+"."+name, bank.Base+at)
+			}
+			if at+size > end {
+				end = at + size
+			}
+		}
+		if end > cursor {
+			cursor = end
+		}
+	}
+	return end - start
+}
+
+func (e *rdlElab) regSize(c *rdlComp) uint64 {
+	width := uint64(32)
+	if v, ok := c.prop("regwidth"); ok {
+		width = e.uint(&v, 32)
+	}
+	if width == 0 {
+		width = 32
+	}
+	return (width + 7) / 8
+}
+
+func (e *rdlElab) reg(c *rdlComp, bank *Bank, name string, offset uint64) uint64 {
+	width := 32
+	if v, ok := c.prop("regwidth"); ok {
+		if w := e.uint(&v, 0); w == 0 || w > 4096 {
+			e.errs.Add(v.pos.String(), "无效regwidth %q", v.text)
+		} else {
+			width = int(w)
+		}
+	}
+	layout := &Layout{
+		Name:        name,
+		Description: e.str(c, "desc", "name"),
+		Width:       width,
+		Offset:      offset,
+		Reset:       new(big.Int),
+	}
+	next := 0
+	for _, inst := range c.insts {
+		if inst.comp.kind != "field" {
+			e.errs.Add(inst.pos.String(), "reg内只能实例化field")
+			continue
+		}
+		if f, ok := e.field(inst, width, &next, layout.Reset); ok {
+			layout.Fields = append(layout.Fields, f)
+		}
+	}
+	bank.Registers = append(bank.Registers, layout)
+	return uint64((width + 7) / 8)
+}
+
+func (e *rdlElab) field(inst *rdlInst, width int, next *int, reset *big.Int) (Field, bool) {
+	c := inst.comp
+	pos := inst.pos.String()
+	lsb := uint64(*next)
+	msb := lsb
+	switch len(inst.ranges) {
+	case 0:
+		if v, ok := c.prop("fieldwidth"); ok {
+			fieldWidth, ok := e.fieldWidth(&v, lsb, width)
+			if !ok {
+				return Field{}, false
+			}
+			msb = lsb + fieldWidth - 1
+		}
+	case 1:
+		r := inst.ranges[0]
+		if len(r) == 1 {
+			fieldWidth, ok := e.fieldWidth(&r[0], lsb, width)
+			if !ok {
+				return Field{}, false
+			}
+			msb = lsb + fieldWidth - 1
+		} else {
+			msb, lsb = e.uint(&r[0], 0), e.uint(&r[1], 0)
+			if msb < lsb {
+				msb, lsb = lsb, msb
+			}
+		}
+	default:
+		e.errs.Add(pos, "field不支持数组")
+		return Field{}, false
+	}
+	if msb >= uint64(width) {
+		e.errs.Add(pos, "位范围[%d:%d]超出寄存器宽度%d", msb, lsb, width)
+		return Field{}, false
+	}
+	*next = int(msb) + 1
+	f := Field{
+		Name:        inst.name,
+		Msb:         int(msb),
+		Lsb:         int(lsb),
+		Description: e.str(c, "desc", "name"),
+		Access:      "read-write",
+		Hardware:    "rw",
+	}
+	if v, ok := c.prop("sw"); ok {
+		access, known := rdlSwAccess[v.text]
+		if !known {
+			e.errs.Add(v.pos.String(), "无效sw %q", v.text)
+		}
+		f.Access = access
+	}
+	if v, ok := c.prop("hw"); ok {
+		f.Hardware = v.text
+	}
+	if v, ok := c.prop("onwrite"); ok {
+		f.WriteAction = rdlOnWrite[v.text]
+	}
+	if v, ok := c.prop("onread"); ok {
+		f.ReadAction = rdlOnRead[v.text]
+	}
+	for prop, action := range rdlOnWrite {
+		if v, ok := c.prop(prop); ok && v.text == "true" {
+			f.WriteAction = action
+		}
+	}
+	for prop, action := range rdlOnRead {
+		if v, ok := c.prop(prop); ok && v.text == "true" {
+			f.ReadAction = action
+		}
+	}
+	value := inst.reset
+	if value == nil {
+		if v, ok := c.prop("reset"); ok {
+			value = &v
+		}
+	}
+	if value != nil {
+		if num, ok := e.num(value); ok {
+			if num.BitLen() > f.Width() {
+				e.errs.Add(value.pos.String(), "复位值%s超出位域宽度%d", value.text, f.Width())
+			} else {
+				reset.Or(reset, num.Lsh(num, uint(f.Lsb)))
+			}
+		}
+	}
+	if v, ok := c.prop("encode"); ok {
+		enum := c.enum(v.text)
+		if enum == nil {
+			e.errs.Add(v.pos.String(), "未定义的enum %s", v.text)
+		}
+		e.enums(&f, enum)
+	}
+	return f, true
+}
+
+func (e *rdlElab) enums(f *Field, enum *rdlEnum) {
+	if enum == nil {
+		return
+	}
+	next := new(big.Int)
+	for _, v := range enum.values {
+		if v.value != nil {
+			num, ok := e.num(v.value)
+			if !ok {
+				continue
+			}
+			next = num
+		}
+		addEnum(e.errs, v.pos.String(), f, v.name, next.String(), v.desc)
+		next = new(big.Int).Add(next, big.NewInt(1))
+	}
+}
+
+// LoadRDL compiles the supported SystemRDL subset. Every addrmap that is not
+// instantiated elsewhere is a root; it and each nested addrmap instance
+// become a bank, regfiles are flattened into their addrmap. Positions in
+// errors are reported as file:line:col, the file name is taken from r when it
+// is an *os.File.
+func LoadRDL(r io.Reader) (*RegisterMap, error) {
+	file := "<rdl>"
+	if named, ok := r.(interface{ Name() string }); ok {
+		file = filepath.Base(named.Name())
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := rdlLex(file, string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &rdlParser{toks: toks}
+	root := newRDLComp("", rdlPos{file, 1, 1}, nil)
+	for p.peek().kind != 0 {
+		if err := p.item(root); err != nil {
+			return nil, err
+		}
+	}
+	regMap := new(RegisterMap)
+	e := &rdlElab{errs: &p.errs, regMap: regMap}
+	for _, inst := range root.insts {
+		e.errs.Add(inst.pos.String(), "顶层不能实例化%s", inst.comp.kind)
+	}
+	for _, def := range root.defs {
+		if def.kind == "addrmap" && !def.used {
+			regMap.Name = def.name
+			regMap.Description = e.str(def, "desc", "name")
+			e.addrmap(def, def.name, 0)
+		}
+	}
+	if regMap.Name == "" {
+		e.errs.Add(root.pos.String(), "未找到addrmap")
+	}
+	return validated(regMap, p.errs)
+}
@@ -0,0 +1,99 @@
+package regmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+const rdlSample = `
+// timer block
+addrmap timer {
+    name = "Timer";
+    enum ckd_e {
+        DIV1 = 2'h0;
+        DIV2;
+        DIV4 { desc = "clock / 4"; };
+    };
+    reg {
+        regwidth = 32;
+        field { sw = rw; hw = r; } CEN[0:0] = 1;
+        field { sw = rw; encode = ckd_e; } CKD[9:8] = 0;
+        field { sw = r; } DIR[4:4];
+        field { sw = na; } RES[31:16];
+    } CR1 @ 0x0;
+    reg {
+        field { sw = rw; woclr; } UIF[0:0];
+    } SR[2] @ 0x10;
+};
+`
+
+func TestLoadRDL(t *testing.T) {
+	m, err := LoadRDL(strings.NewReader(rdlSample))
+	if msg := loaded(t, m, err); msg != "" {
+		t.Fatalf("errors: %s", msg)
+	}
+	if m.Name != "timer" || m.Description != "Timer" || len(m.Banks) != 1 {
+		t.Fatalf("map %s %q with %d banks", m.Name, m.Description, len(m.Banks))
+	}
+	cr1 := layout(t, m, "timer", "CR1")
+	if cr1.Width != 32 || cr1.Reset.Int64() != 1 {
+		t.Errorf("CR1 width %d reset 0x%x", cr1.Width, cr1.Reset)
+	}
+	access := map[string]string{"CEN": "read-write", "CKD": "read-write", "DIR": "read-only"}
+	for name, want := range access {
+		if f := cr1.Field(name); f == nil || f.Access != want {
+			t.Errorf("%s = %+v, want %v", name, f, want)
+		}
+	}
+	if res := cr1.Field("RES"); res == nil || res.AccessType() != Reserved {
+		t.Errorf("RES = %+v, want reserved", res)
+	}
+	ckd := cr1.Field("CKD")
+	if len(ckd.Enums) != 3 || ckd.Enums[2].Value.Int64() != 2 || ckd.Enums[2].Description != "clock / 4" {
+		t.Errorf("CKD enums = %+v", ckd.Enums)
+	}
+	sr1 := layout(t, m, "timer", "SR[1]")
+	if sr1.Offset != 0x14 || sr1.Field("UIF").WriteAction != "oneToClear" {
+		t.Errorf("SR1 = %+v", sr1)
+	}
+}
+
+func TestLoadRDLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"range", `addrmap a { reg { field {} F[40:0]; } R; };`, "超出寄存器宽度"},
+		{"sw", `addrmap a { reg { field { sw = rx; } F[0:0]; } R; };`, "无效sw"},
+		{"reset", `addrmap a { reg { field {} F[1:0] = 7; } R; };`, "复位值7超出位域宽度2"},
+		{"encode", `addrmap a { reg { field { encode = e; } F[1:0]; } R; };`, "未定义的enum e"},
+		{"root", `reg r { field {} F; };`, "未找到addrmap"},
+		{"fieldwidth 0", `addrmap a { reg { field { fieldwidth = 0; } F; } R; };`, "无效位域宽度"},
+		{"fieldwidth wraps", `addrmap a { reg { field {} E[4]; field { fieldwidth = 0xFFFFFFFFFFFFFFFF; } F; } R; };`, "超出寄存器宽度32"},
+		{"fieldwidth too wide", `addrmap a { reg { field {} E[4]; field { fieldwidth = 29; } F; } R; };`, "位域宽度29超出寄存器宽度32"},
+		{"[N] 0", `addrmap a { reg { field {} F[0]; } R; };`, "无效位域宽度"},
+		{"[N] wraps", `addrmap a { reg { field {} E[4]; field {} F[0xFFFFFFFFFFFFFFFF]; } R; };`, "超出寄存器宽度32"},
+		{"register full", `addrmap a { reg { field {} E[32]; field {} F[1]; } R; };`, "位域宽度1超出寄存器宽度32"},
+		{"array product", `addrmap a { reg { field {} F; } R[64][128]; };`, "数组元素超过4096个"},
+	}
+	for _, tt := range tests {
+		m, err := LoadRDL(strings.NewReader(tt.src))
+		if msg := loaded(t, m, err); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: errors %q, want %q", tt.name, msg, tt.want)
+		}
+		// what was read must be usable
+		for _, b := range m.Banks {
+			for _, l := range b.Registers {
+				for _, f := range l.Fields {
+					if f.Lsb < 0 || f.Msb < f.Lsb || f.Msb >= l.Width {
+						t.Errorf("%s: field %s[%d:%d] in %d bits", tt.name, f.Name, f.Msb, f.Lsb, l.Width)
+					}
+				}
+			}
+		}
+	}
+	if _, err := LoadRDL(strings.NewReader(`addrmap a { reg { field {} F } R; };`)); err == nil {
+		t.Error("syntax error accepted")
+	}
+}
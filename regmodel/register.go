@@ -0,0 +1,359 @@
+// Package regmodel holds the register state behind the analyzer front ends,
+// independent of any GUI toolkit.
+package regmodel
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+var ErrInvalid = errors.New("无效输入")
+
+// Widths are the presets offered by the width selectors; any positive width
+// is accepted.
+var Widths = []int{8, 16, 24, 32, 64, 128}
+
+// Register is the value of one analyzer row. Bit i is the bit of weight 2^i;
+// front ends draw bit Width()-1 in the leftmost column.
+type Register struct {
+	width    int
+	value    big.Int
+	mask     big.Int
+	reset    big.Int
+	hasReset bool
+	layout   *Layout
+}
+
+func Mask(width int) *big.Int {
+	mask := big.NewInt(1)
+	mask.Lsh(mask, uint(width))
+	return mask.Sub(mask, big.NewInt(1))
+}
+
+func NewRegister(width int) *Register {
+	reg := new(Register)
+	reg.SetWidth(width)
+	return reg
+}
+
+// Set makes r a copy of o: width, value, reset value and layout.
+func (r *Register) Set(o *Register) {
+	r.width = o.width
+	r.value.Set(&o.value)
+	r.mask.Set(&o.mask)
+	r.reset.Set(&o.reset)
+	r.hasReset = o.hasReset
+	r.layout = o.layout
+}
+
+func (r *Register) Clone() *Register {
+	c := new(Register)
+	c.Set(r)
+	return c
+}
+
+// Equal reports whether r and o have the same width, value, reset value and
+// layout.
+func (r *Register) Equal(o *Register) bool {
+	return r.width == o.width && r.value.Cmp(&o.value) == 0 &&
+		r.hasReset == o.hasReset && r.reset.Cmp(&o.reset) == 0 && r.layout == o.layout
+}
+
+func (r *Register) Width() int {
+	return r.width
+}
+
+// SetWidth changes the register width, dropping bits that no longer fit.
+func (r *Register) SetWidth(width int) {
+	if width < 1 {
+		return
+	}
+	r.width = width
+	r.mask.Set(Mask(width))
+	r.value.And(&r.value, &r.mask)
+	r.reset.And(&r.reset, &r.mask)
+}
+
+func (r *Register) MaxNum() *big.Int {
+	return new(big.Int).Set(&r.mask)
+}
+
+func (r *Register) Value() *big.Int {
+	return new(big.Int).Set(&r.value)
+}
+
+func (r *Register) SetValue(v *big.Int) {
+	r.value.And(v, &r.mask)
+}
+
+// SetString parses s in the given base, ignoring '_' separators. Negative
+// values are stored in two's complement. Values that are malformed or do not
+// fit in the register leave it unchanged and return ErrInvalid.
+func (r *Register) SetString(s string, base int) error {
+	if s == "" {
+		r.value.SetInt64(0)
+		return nil
+	}
+	v, ok := new(big.Int).SetString(strings.ReplaceAll(s, "_", ""), base)
+	min := new(big.Int).Lsh(big.NewInt(-1), uint(r.width-1))
+	if !ok || v.Cmp(&r.mask) > 0 || v.Cmp(min) < 0 {
+		return ErrInvalid
+	}
+	r.value.And(v, &r.mask)
+	return nil
+}
+
+func (r *Register) Text(base int) string {
+	return FormatNumber(&r.value, base)
+}
+
+// FormatNumber writes v in base; binary is grouped by nibble with '_', which
+// SetString and ParseNumber accept back.
+func FormatNumber(v *big.Int, base int) string {
+	text := v.Text(base)
+	if base != 2 {
+		return text
+	}
+	sign := ""
+	if text[0] == '-' {
+		sign, text = "-", text[1:]
+	}
+	var b strings.Builder
+	for i, c := range text {
+		if i > 0 && (len(text)-i)%4 == 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
+
+// Bits returns the value in binary, most significant bit first, padded to the
+// register width.
+func (r *Register) Bits() string {
+	return fmt.Sprintf("%0*s", r.width, r.value.Text(2))
+}
+
+func (r *Register) Bit(i int) uint {
+	return r.value.Bit(i)
+}
+
+func (r *Register) SetBit(i int, b uint) {
+	if i < 0 || i >= r.width {
+		return
+	}
+	r.value.SetBit(&r.value, i, b)
+}
+
+func (r *Register) Toggle(i int) {
+	r.SetBit(i, r.Bit(i)^1)
+}
+
+func (r *Register) Lsh(n uint) {
+	r.value.Lsh(&r.value, n)
+	r.value.And(&r.value, &r.mask)
+}
+
+func (r *Register) Rsh(n uint) {
+	r.value.Rsh(&r.value, n)
+}
+
+// Rol rotates left within the register width.
+func (r *Register) Rol(n uint) {
+	n %= uint(r.width)
+	low := new(big.Int).Rsh(&r.value, uint(r.width)-n)
+	r.value.Lsh(&r.value, n)
+	r.value.Or(&r.value, low)
+	r.value.And(&r.value, &r.mask)
+}
+
+func (r *Register) Ror(n uint) {
+	r.Rol(uint(r.width) - n%uint(r.width))
+}
+
+// Asr shifts right copying the sign bit into the top.
+func (r *Register) Asr(n uint) {
+	v := r.Signed()
+	r.value.And(v.Rsh(v, n), &r.mask)
+}
+
+// Within applies op to bits hi down to lo alone, as a register of their
+// own width; the other bits keep their value.
+func (r *Register) Within(hi, lo int, op func(*Register)) {
+	if hi < lo {
+		hi, lo = lo, hi
+	}
+	if hi >= r.width {
+		hi = r.width - 1
+	}
+	if lo > hi {
+		return
+	}
+	sub := NewRegister(hi - lo + 1)
+	sub.SetValue(r.Extract(hi, lo))
+	op(sub)
+	r.SetField(&Field{Msb: hi, Lsb: lo}, sub.Value())
+}
+
+func (r *Register) Reverse() {
+	var rev big.Int
+	for i := 0; i < r.width; i++ {
+		rev.SetBit(&rev, r.width-1-i, r.value.Bit(i))
+	}
+	r.value.Set(&rev)
+}
+
+// Swap reverses the order of the unit-bit pieces inside every group-bit
+// group: Swap(8, 32) is a 32-bit byte swap, Swap(16, 32) swaps halfwords and
+// Swap(4, 8) the nibbles of each byte. Bits above the last whole group keep
+// their place.
+func (r *Register) Swap(unit, group int) {
+	res := new(big.Int).Set(&r.value)
+	piece := new(big.Int)
+	for base := 0; base+group <= r.width; base += group {
+		for i := 0; i < group/unit; i++ {
+			piece.Rsh(&r.value, uint(base+i*unit))
+			piece.And(piece, Mask(unit))
+			at := uint(base + group - (i+1)*unit)
+			res.AndNot(res, new(big.Int).Lsh(Mask(unit), at))
+			res.Or(res, piece.Lsh(piece, at))
+		}
+	}
+	r.value.Set(res)
+}
+
+// Bytes is the value as it is laid out in memory, lowest address first, in
+// big or little endian order. A partial top byte is padded with zeros.
+func (r *Register) Bytes(bigEndian bool) []byte {
+	b := r.value.FillBytes(make([]byte, (r.width+7)/8))
+	if !bigEndian {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	return b
+}
+
+func (r *Register) Invert() {
+	r.value.Xor(&r.value, &r.mask)
+}
+
+func (r *Register) Clear() {
+	r.value.SetInt64(0)
+}
+
+// Stats are the bit counts of a value. Highest and Lowest are -1 for zero.
+type Stats struct {
+	Ones          int
+	LeadingZeros  int
+	TrailingZeros int
+	Highest       int
+	Lowest        int
+	PowerOfTwo    bool
+}
+
+// OddParity reports an odd number of set bits.
+func (s Stats) OddParity() bool {
+	return s.Ones%2 == 1
+}
+
+func (r *Register) Stats() Stats {
+	s := Stats{Highest: r.value.BitLen() - 1, Lowest: -1, TrailingZeros: r.width}
+	for _, w := range r.value.Bits() {
+		s.Ones += bits.OnesCount(uint(w))
+	}
+	s.LeadingZeros = r.width - r.value.BitLen()
+	if s.Ones > 0 {
+		s.Lowest = int(r.value.TrailingZeroBits())
+		s.TrailingZeros = s.Lowest
+	}
+	s.PowerOfTwo = s.Ones == 1
+	return s
+}
+
+// Signed is the value read as a two's complement number.
+func (r *Register) Signed() *big.Int {
+	return Signed(&r.value, r.width)
+}
+
+// Signed reads the low width bits of v as a two's complement number.
+func Signed(v *big.Int, width int) *big.Int {
+	res := new(big.Int).And(v, Mask(width))
+	if width > 0 && res.Bit(width-1) == 1 {
+		res.Sub(res, new(big.Int).Lsh(big.NewInt(1), uint(width)))
+	}
+	return res
+}
+
+// Extract returns bits hi down to lo as an unsigned number.
+func (r *Register) Extract(hi, lo int) *big.Int {
+	if hi < lo {
+		hi, lo = lo, hi
+	}
+	res := new(big.Int).Rsh(&r.value, uint(lo))
+	return res.And(res, Mask(hi-lo+1))
+}
+
+func (r *Register) Layout() *Layout {
+	return r.layout
+}
+
+func (r *Register) SetLayout(l *Layout) {
+	r.layout = l
+}
+
+func (r *Register) FieldAt(bit int) *Field {
+	if r.layout == nil {
+		return nil
+	}
+	return r.layout.FieldAt(bit)
+}
+
+func (r *Register) FieldValue(f *Field) *big.Int {
+	return r.Extract(f.Msb, f.Lsb)
+}
+
+// SetField writes v into the bits of f, truncated to the field width.
+func (r *Register) SetField(f *Field, v *big.Int) {
+	mask := f.Mask()
+	bits := new(big.Int).And(v, Mask(f.Width()))
+	r.value.AndNot(&r.value, mask)
+	r.value.Or(&r.value, bits.Lsh(bits, uint(f.Lsb)))
+	r.value.And(&r.value, &r.mask)
+}
+
+// Reset is the power-on value of the register, nil when it is not known.
+func (r *Register) Reset() *big.Int {
+	if !r.hasReset {
+		return nil
+	}
+	return new(big.Int).Set(&r.reset)
+}
+
+// SetReset records the reset value; nil forgets it.
+func (r *Register) SetReset(v *big.Int) {
+	r.hasReset = v != nil
+	if v == nil {
+		r.reset.SetInt64(0)
+		return
+	}
+	r.reset.And(v, &r.mask)
+}
+
+// LoadReset sets the value back to the reset value, if there is one.
+func (r *Register) LoadReset() {
+	if r.hasReset {
+		r.value.Set(&r.reset)
+	}
+}
+
+// ResetDiff has the bits that differ from the reset value set.
+func (r *Register) ResetDiff() *big.Int {
+	if !r.hasReset {
+		return new(big.Int)
+	}
+	return new(big.Int).Xor(&r.value, &r.reset)
+}
@@ -0,0 +1,144 @@
+package regmodel
+
+import (
+	"math/big"
+	"testing"
+)
+
+func hex(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("bad hex %q", s)
+	}
+	return v
+}
+
+func TestRegisterOps(t *testing.T) {
+	tests := []struct {
+		name  string
+		width int
+		value string
+		op    func(*Register)
+		want  string
+	}{
+		{"lsh drops high bits", 8, "81", func(r *Register) { r.Lsh(1) }, "2"},
+		{"rsh", 8, "81", func(r *Register) { r.Rsh(4) }, "8"},
+		{"rol", 8, "81", func(r *Register) { r.Rol(1) }, "3"},
+		{"rol by width", 8, "81", func(r *Register) { r.Rol(8) }, "81"},
+		{"ror", 8, "81", func(r *Register) { r.Ror(1) }, "c0"},
+		{"asr negative", 8, "80", func(r *Register) { r.Asr(2) }, "e0"},
+		{"asr positive", 8, "40", func(r *Register) { r.Asr(2) }, "10"},
+		{"reverse", 8, "1", func(r *Register) { r.Reverse() }, "80"},
+		{"reverse 12 bits", 12, "3", func(r *Register) { r.Reverse() }, "c00"},
+		{"invert", 12, "f0", func(r *Register) { r.Invert() }, "f0f"},
+		{"clear", 16, "ffff", func(r *Register) { r.Clear() }, "0"},
+		{"toggle", 8, "0", func(r *Register) { r.Toggle(7) }, "80"},
+		{"byte swap 32", 32, "11223344", func(r *Register) { r.Swap(8, 32) }, "44332211"},
+		{"halfword swap", 32, "11223344", func(r *Register) { r.Swap(16, 32) }, "33441122"},
+		{"nibble swap", 16, "1234", func(r *Register) { r.Swap(4, 8) }, "2143"},
+		{"swap keeps partial group", 24, "aa1122", func(r *Register) { r.Swap(8, 16) }, "aa2211"},
+		{"within rotate", 16, "1234", func(r *Register) { r.Within(7, 4, func(s *Register) { s.Rol(1) }) }, "1264"},
+		{"within swapped range", 16, "00f0", func(r *Register) { r.Within(4, 7, func(s *Register) { s.Clear() }) }, "0"},
+		{"set width truncates", 16, "1234", func(r *Register) { r.SetWidth(8) }, "34"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegister(tt.width)
+			r.SetValue(hex(t, tt.value))
+			tt.op(r)
+			if got := r.Value().Text(16); got != tt.want {
+				t.Errorf("got 0x%s, want 0x%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterSetString(t *testing.T) {
+	tests := []struct {
+		in    string
+		base  int
+		want  string
+		valid bool
+	}{
+		{"ff", 16, "ff", true},
+		{"1111_0000", 2, "f0", true},
+		{"-1", 10, "ff", true},
+		{"-128", 10, "80", true},
+		{"-129", 10, "", false},
+		{"256", 10, "", false},
+		{"0b11", 16, "", false},
+		{"", 16, "0", true},
+		{"zz", 16, "", false},
+	}
+	for _, tt := range tests {
+		r := NewRegister(8)
+		err := r.SetString(tt.in, tt.base)
+		if (err == nil) != tt.valid {
+			t.Errorf("SetString(%q, %d) error %v, want valid %v", tt.in, tt.base, err, tt.valid)
+			continue
+		}
+		if tt.valid && r.Value().Text(16) != tt.want {
+			t.Errorf("SetString(%q, %d) = 0x%s, want 0x%s", tt.in, tt.base, r.Value().Text(16), tt.want)
+		}
+	}
+}
+
+func TestRegisterFields(t *testing.T) {
+	r := NewRegister(32)
+	f := &Field{Name: "MODE", Msb: 7, Lsb: 4}
+	r.SetValue(hex(t, "ffffffff"))
+	r.SetField(f, big.NewInt(0x35))
+	if got := r.Value().Text(16); got != "ffffff5f" {
+		t.Errorf("SetField = 0x%s, want 0xffffff5f", got)
+	}
+	if got := r.FieldValue(f).Int64(); got != 5 {
+		t.Errorf("FieldValue = %d, want 5", got)
+	}
+	if got := r.Extract(3, 0).Int64(); got != 0xf {
+		t.Errorf("Extract(3, 0) = %d, want 15", got)
+	}
+	if got := Signed(big.NewInt(0xfe), 8).Int64(); got != -2 {
+		t.Errorf("Signed(0xfe, 8) = %d, want -2", got)
+	}
+}
+
+func TestRegisterReset(t *testing.T) {
+	r := NewRegister(8)
+	if r.Reset() != nil {
+		t.Fatal("new register has a reset value")
+	}
+	r.SetReset(big.NewInt(0x1a5))
+	r.SetValue(big.NewInt(0xff))
+	if got := r.ResetDiff().Int64(); got != 0x5a {
+		t.Errorf("ResetDiff = %#x, want 0x5a", got)
+	}
+	r.LoadReset()
+	if got := r.Value().Int64(); got != 0xa5 {
+		t.Errorf("LoadReset = %#x, want 0xa5", got)
+	}
+	c := r.Clone()
+	c.Clear()
+	if !r.Equal(r.Clone()) || r.Equal(c) {
+		t.Error("Clone/Equal do not track the value")
+	}
+}
+
+func TestRegisterStats(t *testing.T) {
+	tests := []struct {
+		width int
+		value string
+		want  Stats
+	}{
+		{16, "0", Stats{Ones: 0, LeadingZeros: 16, TrailingZeros: 16, Highest: -1, Lowest: -1}},
+		{16, "8", Stats{Ones: 1, LeadingZeros: 12, TrailingZeros: 3, Highest: 3, Lowest: 3, PowerOfTwo: true}},
+		{16, "8010", Stats{Ones: 2, LeadingZeros: 0, TrailingZeros: 4, Highest: 15, Lowest: 4}},
+	}
+	for _, tt := range tests {
+		r := NewRegister(tt.width)
+		r.SetValue(hex(t, tt.value))
+		if got := r.Stats(); got != tt.want {
+			t.Errorf("Stats(0x%s) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package regmodel
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RegisterMap is a loaded register description, grouped into banks
+// (SVD peripherals, IP-XACT address blocks, ...).
+type RegisterMap struct {
+	Name        string
+	Description string
+	Banks       []*Bank
+}
+
+type Bank struct {
+	Name        string
+	Description string
+	Base        uint64
+	Registers   []*Layout
+}
+
+func (b *Bank) Register(name string) *Layout {
+	for _, l := range b.Registers {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func (m *RegisterMap) Bank(name string) *Bank {
+	for _, b := range m.Banks {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// ErrorList collects the problems found while importing a description; the
+// importers keep going and return whatever they could read.
+type ErrorList []error
+
+func (e ErrorList) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ErrorList) Add(path, format string, a ...interface{}) {
+	*e = append(*e, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, a...)))
+}
+
+func (e ErrorList) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validated appends the findings of Validate to the import errors.
+func validated(regMap *RegisterMap, errs ErrorList) (*RegisterMap, error) {
+	if err, ok := regMap.Validate().(ErrorList); ok {
+		errs = append(errs, err...)
+	}
+	return regMap, errs.Err()
+}
+
+// addEnum appends a named encoding to f, skipping values that do not fit the
+// field or repeat an earlier one.
+func addEnum(errs *ErrorList, path string, f *Field, name, value, description string) {
+	num, err := ParseNumber(value)
+	if err != nil {
+		errs.Add(path, "枚举%s: %v", name, err)
+		return
+	}
+	if num.BitLen() > f.Width() {
+		errs.Add(path, "枚举%s: 值%s超出位域宽度%d", name, value, f.Width())
+		return
+	}
+	if f.Enum(num) != nil {
+		return
+	}
+	f.Enums = append(f.Enums, Enum{Name: name, Value: num, Description: clean(description)})
+}
+
+// ParseNumber accepts the integer notations found in register descriptions:
+// decimal, 0x/0b/0o prefixes, SVD's #binary, Verilog sized literals such as
+// 8'h1F, '_' separators and k/M/G/T suffixes.
+func ParseNumber(s string) (*big.Int, error) {
+	str := strings.ReplaceAll(strings.TrimSpace(s), "_", "")
+	str = strings.TrimPrefix(str, "+")
+	if str == "" {
+		return nil, fmt.Errorf("无效数值 %q", s)
+	}
+	scale := uint(0)
+	switch str[len(str)-1] {
+	case 'k', 'K':
+		scale = 10
+	case 'm', 'M':
+		scale = 20
+	case 'g', 'G':
+		scale = 30
+	case 't', 'T':
+		scale = 40
+	}
+	base := 10
+	lower := strings.ToLower(str)
+	if ix := strings.Index(lower, "'"); ix >= 0 {
+		lower = strings.TrimPrefix(lower[ix+1:], "s")
+		if lower == "" {
+			return nil, fmt.Errorf("无效数值 %q", s)
+		}
+		switch lower[0] {
+		case 'h':
+			base = 16
+		case 'b':
+			base = 2
+		case 'o':
+			base = 8
+		case 'd':
+			base = 10
+		default:
+			return nil, fmt.Errorf("无效数值 %q", s)
+		}
+		str, scale = lower[1:], 0
+	} else if strings.HasPrefix(lower, "0x") {
+		str, base, scale = str[2:], 16, 0
+	} else if strings.HasPrefix(lower, "0b") {
+		str, base, scale = str[2:], 2, 0
+	} else if strings.HasPrefix(lower, "0o") {
+		str, base = str[2:], 8
+	} else if strings.HasPrefix(str, "#") {
+		str, base = str[1:], 2
+	}
+	if scale > 0 {
+		str = str[:len(str)-1]
+	}
+	num, ok := new(big.Int).SetString(str, base)
+	if !ok || num.Sign() < 0 {
+		return nil, fmt.Errorf("无效数值 %q", s)
+	}
+	return num.Lsh(num, scale), nil
+}
+
+func parseUint(s string) (uint64, error) {
+	num, err := ParseNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if !num.IsUint64() {
+		return 0, fmt.Errorf("数值过大 %q", s)
+	}
+	return num.Uint64(), nil
+}
@@ -0,0 +1,86 @@
+package regmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"42", "42", true},
+		{"+42", "42", true},
+		{"0x1F", "31", true},
+		{"0X1f", "31", true},
+		{"0b1010", "10", true},
+		{"0o17", "15", true},
+		{"#1010", "10", true},
+		{"8'h1F", "31", true},
+		{"4'b1010", "10", true},
+		{"16'sd5", "5", true},
+		{"1_000", "1000", true},
+		{"4k", "4096", true},
+		{"2M", "2097152", true},
+		{"1G", "1073741824", true},
+		{"0x1k", "", false},
+		{"", "", false},
+		{"-1", "", false},
+		{"8'q1", "", false},
+		{"abc", "", false},
+	}
+	for _, tt := range tests {
+		num, err := ParseNumber(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseNumber(%q) error %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && num.String() != tt.want {
+			t.Errorf("ParseNumber(%q) = %s, want %s", tt.in, num, tt.want)
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	var errs ErrorList
+	if errs.Err() != nil {
+		t.Fatal("empty ErrorList is an error")
+	}
+	errs.Add("A.B", "无效%s", "x")
+	errs.Add("A.C", "y")
+	if got := errs.Err().Error(); got != "A.B: 无效x\nA.C: y" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+// loaded fails the test when the import returned no map, and returns the
+// import errors as one string for matching.
+func loaded(t *testing.T, m *RegisterMap, err error) string {
+	t.Helper()
+	if m == nil {
+		t.Fatalf("no map: %v", err)
+	}
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func layout(t *testing.T, m *RegisterMap, bank, name string) *Layout {
+	t.Helper()
+	b := m.Bank(bank)
+	if b == nil {
+		t.Fatalf("no bank %s", bank)
+	}
+	l := b.Register(name)
+	if l == nil {
+		var names []string
+		for _, r := range b.Registers {
+			names = append(names, r.Name)
+		}
+		t.Fatalf("no register %s.%s in %s", bank, name, strings.Join(names, " "))
+	}
+	return l
+}
@@ -0,0 +1,212 @@
+package regmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Session is the saved state of the analyzer: every row with its value,
+// width and label, the display settings and the loaded register map.
+type Session struct {
+	Width     int
+	Base      int
+	LSB       bool
+	Analyze   string
+	BitColor  uint32
+	DiffColor uint32
+	Rows      []SessionRow
+	Map       *RegisterMap
+}
+
+// SessionRow is one row of a session. Show is the display mode of the
+// number input as numbered by the front end; Fixed is only used by the
+// fixed point mode.
+type SessionRow struct {
+	Register *Register
+	Label    string
+	Note     string
+	Tag      int
+	Show     int
+	Fixed    QFormat
+}
+
+type sessionFile struct {
+	Width     int       `json:"width" yaml:"width"`
+	Base      int       `json:"base" yaml:"base"`
+	LSB       bool      `json:"lsb,omitempty" yaml:"lsb,omitempty"`
+	Analyze   string    `json:"analyze,omitempty" yaml:"analyze,omitempty"`
+	BitColor  uint32    `json:"bitColor,omitempty" yaml:"bitColor,omitempty"`
+	DiffColor uint32    `json:"diffColor,omitempty" yaml:"diffColor,omitempty"`
+	Rows      []rowFile `json:"rows" yaml:"rows"`
+	Map       *mapFile  `json:"map,omitempty" yaml:"map,omitempty"`
+}
+
+type rowFile struct {
+	Width  int           `json:"width" yaml:"width"`
+	Value  mapNumber     `json:"value" yaml:"value"`
+	Reset  mapNumber     `json:"reset,omitempty" yaml:"reset,omitempty"`
+	Label  string        `json:"label,omitempty" yaml:"label,omitempty"`
+	Note   string        `json:"note,omitempty" yaml:"note,omitempty"`
+	Tag    int           `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Show   int           `json:"show,omitempty" yaml:"show,omitempty"`
+	Format string        `json:"format,omitempty" yaml:"format,omitempty"`
+	Layout *registerFile `json:"layout,omitempty" yaml:"layout,omitempty"`
+}
+
+func (s *Session) file() *sessionFile {
+	file := &sessionFile{
+		Width:     s.Width,
+		Base:      s.Base,
+		LSB:       s.LSB,
+		Analyze:   s.Analyze,
+		BitColor:  s.BitColor,
+		DiffColor: s.DiffColor,
+	}
+	for _, row := range s.Rows {
+		reg := row.Register
+		rf := rowFile{
+			Width: reg.Width(),
+			Value: mapNumber("0x" + reg.Value().Text(16)),
+			Label: row.Label,
+			Note:  row.Note,
+			Tag:   row.Tag,
+			Show:  row.Show,
+		}
+		if reset := reg.Reset(); reset != nil {
+			rf.Reset = mapNumber("0x" + reset.Text(16))
+		}
+		if row.Fixed.Width() > 0 {
+			rf.Format = row.Fixed.String()
+		}
+		if l := reg.Layout(); l != nil {
+			layout := layoutFile(l)
+			rf.Layout = &layout
+		}
+		file.Rows = append(file.Rows, rf)
+	}
+	if s.Map != nil {
+		file.Map = s.Map.file()
+	}
+	return file
+}
+
+func (s *Session) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.file())
+}
+
+// LoadSession reads a session written by WriteJSON. Like LoadMap it keeps
+// what it could read and returns the problems as an ErrorList; rows that
+// cannot be restored are left out.
+func LoadSession(r io.Reader) (*Session, error) {
+	var file sessionFile
+	if err := yaml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("会话解析失败: %v", err)
+	}
+	var errs ErrorList
+	s := &Session{
+		Width:     file.Width,
+		Base:      file.Base,
+		LSB:       file.LSB,
+		Analyze:   file.Analyze,
+		BitColor:  file.BitColor,
+		DiffColor: file.DiffColor,
+	}
+	if s.Width < 1 || s.Width > maxFileBits {
+		errs.Add("width", "无效位宽 %d", s.Width)
+		s.Width = 32
+	}
+	switch s.Base {
+	case 2, 8, 10, 16, 36:
+	default:
+		errs.Add("base", "无效进制 %d", s.Base)
+		s.Base = 16
+	}
+	for i, rf := range file.Rows {
+		path := fmt.Sprintf("rows[%d]", i)
+		if rf.Width < 1 || rf.Width > maxFileBits {
+			errs.Add(path, "无效位宽 %d", rf.Width)
+			continue
+		}
+		reg := NewRegister(rf.Width)
+		value, err := ParseNumber(string(rf.Value))
+		switch {
+		case err != nil:
+			errs.Add(path, "无效value %q", rf.Value)
+		case value.Sign() < 0 || value.BitLen() > rf.Width:
+			errs.Add(path, "值%s超出位宽%d", rf.Value, rf.Width)
+		default:
+			reg.SetValue(value)
+		}
+		if rf.Reset != "" {
+			if reset, err := ParseNumber(string(rf.Reset)); err != nil {
+				errs.Add(path, "无效reset %q", rf.Reset)
+			} else {
+				reg.SetReset(reset)
+			}
+		}
+		row := SessionRow{Register: reg, Label: rf.Label, Note: rf.Note, Tag: rf.Tag, Show: rf.Show}
+		if rf.Format != "" {
+			if row.Fixed, err = ParseQFormat(rf.Format, rf.Width); err != nil {
+				errs.Add(path, "%v", err)
+			}
+		}
+		if rf.Layout != nil {
+			lpath := path + "." + rf.Layout.Name
+			layout := rf.Layout.layout(&errs, lpath)
+			validateLayout(&errs, lpath, layout)
+			reg.SetLayout(layout)
+		}
+		s.Rows = append(s.Rows, row)
+	}
+	if len(s.Rows) == 0 {
+		errs.Add("rows", "没有可用的行")
+		s.Rows = append(s.Rows, SessionRow{Register: NewRegister(s.Width)})
+	}
+	if file.Map != nil {
+		var err error
+		s.Map, err = file.Map.regMap()
+		if err, ok := err.(ErrorList); ok {
+			for _, e := range err {
+				errs.Add("map", "%v", e)
+			}
+		}
+	}
+	return s, errs.Err()
+}
+
+// Clone copies s with its registers, so later edits of the rows do not
+// change it. The register map is shared.
+func (s *Session) Clone() *Session {
+	c := *s
+	c.Rows = make([]SessionRow, len(s.Rows))
+	for i, row := range s.Rows {
+		row.Register = row.Register.Clone()
+		c.Rows[i] = row
+	}
+	return &c
+}
+
+// ChangedRows lists the rows that differ between s and o, which must have
+// the same number of rows.
+func (s *Session) ChangedRows(o *Session) []int {
+	var changed []int
+	for i, row := range s.Rows {
+		other := o.Rows[i]
+		if !row.Register.Equal(other.Register) || row.Label != other.Label || row.Note != other.Note ||
+			row.Tag != other.Tag || row.Show != other.Show || row.Fixed != other.Fixed {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// Equal compares the width, base and rows of two sessions; the display
+// settings and the register map are left out.
+func (s *Session) Equal(o *Session) bool {
+	return s.Width == o.Width && s.Base == o.Base && len(s.Rows) == len(o.Rows) && len(s.ChangedRows(o)) == 0
+}
@@ -0,0 +1,68 @@
+package regmodel
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	m, err := LoadMap(strings.NewReader(mapSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegister(32)
+	reg.SetValue(big.NewInt(0x21))
+	reg.SetLayout(layout(t, m, "TIM1", "CR1"))
+	fixed := NewRegister(16)
+	fixed.SetValue(big.NewInt(0x180))
+	q, _ := ParseQFormat("Q7.8", 16)
+	s := &Session{
+		Width: 32, Base: 16, LSB: true, Analyze: "MODE",
+		Rows: []SessionRow{
+			{Register: reg, Label: "CR1", Note: "启动", Tag: 2},
+			{Register: fixed, Show: 3, Fixed: q},
+		},
+		Map: m,
+	}
+	var buf bytes.Buffer
+	if err := s.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != 32 || got.Base != 16 || !got.LSB || got.Analyze != "MODE" || len(got.Rows) != 2 || !reflect.DeepEqual(got.Map, m) {
+		t.Fatalf("session = %+v", got)
+	}
+	// the layout is a copy, so compare everything but it
+	for i, row := range got.Rows {
+		want := s.Rows[i]
+		if !reflect.DeepEqual(row.Register.Layout(), want.Register.Layout()) {
+			t.Errorf("rows[%d] layout differs", i)
+		}
+		row.Register.SetLayout(want.Register.Layout())
+	}
+	if !got.Equal(s) {
+		t.Errorf("rows %v differ", got.ChangedRows(s))
+	}
+}
+
+func TestLoadSessionErrors(t *testing.T) {
+	src := `{"width": 0, "base": 7, "rows": [{"width": 4, "value": "0x1f"}, {"width": 0, "value": "1"}]}`
+	s, err := LoadSession(strings.NewReader(src))
+	if s == nil || err == nil {
+		t.Fatalf("LoadSession = %v, %v", s, err)
+	}
+	for _, want := range []string{"width: 无效位宽 0", "base: 无效进制 7", "rows[0]: 值0x1f超出位宽4", "rows[1]: 无效位宽 0"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("errors %q, want %q", err, want)
+		}
+	}
+	if s.Width != 32 || s.Base != 16 || len(s.Rows) != 1 {
+		t.Errorf("session = %+v", s)
+	}
+}
@@ -0,0 +1,518 @@
+package regmodel
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type svdAny struct {
+	XMLName xml.Name
+}
+
+type svdDevice struct {
+	Name        string          `xml:"name"`
+	Description string          `xml:"description"`
+	Size        string          `xml:"size"`
+	Access      string          `xml:"access"`
+	ResetValue  string          `xml:"resetValue"`
+	Peripherals []svdPeripheral `xml:"peripherals>peripheral"`
+}
+
+type svdPeripheral struct {
+	DerivedFrom  string        `xml:"derivedFrom,attr"`
+	Name         string        `xml:"name"`
+	Description  string        `xml:"description"`
+	BaseAddress  string        `xml:"baseAddress"`
+	Size         string        `xml:"size"`
+	Access       string        `xml:"access"`
+	ResetValue   string        `xml:"resetValue"`
+	Dim          string        `xml:"dim"`
+	DimIncrement string        `xml:"dimIncrement"`
+	DimIndex     string        `xml:"dimIndex"`
+	Registers    *svdRegisters `xml:"registers"`
+	Extra        []svdAny      `xml:",any"`
+}
+
+type svdRegisters struct {
+	Registers []svdRegister `xml:"register"`
+	Clusters  []svdCluster  `xml:"cluster"`
+	Extra     []svdAny      `xml:",any"`
+}
+
+type svdCluster struct {
+	Name          string        `xml:"name"`
+	Description   string        `xml:"description"`
+	AddressOffset string        `xml:"addressOffset"`
+	Dim           string        `xml:"dim"`
+	DimIncrement  string        `xml:"dimIncrement"`
+	DimIndex      string        `xml:"dimIndex"`
+	Registers     []svdRegister `xml:"register"`
+	Clusters      []svdCluster  `xml:"cluster"`
+	Extra         []svdAny      `xml:",any"`
+}
+
+type svdRegister struct {
+	DerivedFrom   string     `xml:"derivedFrom,attr"`
+	Name          string     `xml:"name"`
+	DisplayName   string     `xml:"displayName"`
+	Description   string     `xml:"description"`
+	AddressOffset string     `xml:"addressOffset"`
+	Size          string     `xml:"size"`
+	Access        string     `xml:"access"`
+	ResetValue    string     `xml:"resetValue"`
+	Dim           string     `xml:"dim"`
+	DimIncrement  string     `xml:"dimIncrement"`
+	DimIndex      string     `xml:"dimIndex"`
+	Fields        []svdField `xml:"fields>field"`
+	Extra         []svdAny   `xml:",any"`
+}
+
+type svdField struct {
+	Name         string     `xml:"name"`
+	Description  string     `xml:"description"`
+	BitOffset    string     `xml:"bitOffset"`
+	BitWidth     string     `xml:"bitWidth"`
+	Lsb          string     `xml:"lsb"`
+	Msb          string     `xml:"msb"`
+	BitRange     string     `xml:"bitRange"`
+	Access       string     `xml:"access"`
+	WriteAction  string     `xml:"modifiedWriteValues"`
+	ReadAction   string     `xml:"readAction"`
+	Dim          string     `xml:"dim"`
+	DimIncrement string     `xml:"dimIncrement"`
+	DimIndex     string     `xml:"dimIndex"`
+	Enums        []svdEnums `xml:"enumeratedValues"`
+	Extra        []svdAny   `xml:",any"`
+}
+
+type svdEnums struct {
+	Values []svdEnumValue `xml:"enumeratedValue"`
+}
+
+type svdEnumValue struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Value       string `xml:"value"`
+}
+
+var (
+	svdPeripheralKnown = map[string]bool{
+		"version": true, "alternatePeripheral": true, "groupName": true,
+		"prependToName": true, "appendToName": true, "headerStructName": true,
+		"disableCondition": true, "protection": true, "resetMask": true,
+		"addressBlock": true, "interrupt": true, "dimName": true, "dimArrayIndex": true,
+	}
+	svdClusterKnown = map[string]bool{
+		"alternateCluster": true, "headerStructName": true, "size": true,
+		"access": true, "protection": true, "resetValue": true, "resetMask": true,
+		"dimName": true, "dimArrayIndex": true,
+	}
+	svdRegisterKnown = map[string]bool{
+		"alternateGroup": true, "alternateRegister": true, "protection": true,
+		"resetMask": true, "dataType": true, "modifiedWriteValues": true,
+		"writeConstraint": true, "readAction": true, "dimName": true,
+		"dimArrayIndex": true,
+	}
+	svdFieldKnown = map[string]bool{
+		"writeConstraint": true, "dimName": true, "dimArrayIndex": true,
+	}
+	svdBitRange = regexp.MustCompile(`^\[\s*(\d+)\s*:\s*(\d+)\s*\]$`)
+)
+
+type svdDefaults struct {
+	size   int
+	access string
+	reset  *big.Int
+}
+
+// maxSVDRegisters bounds the registers of a device once all arrays are
+// expanded; dim is capped per level, nested clusters multiply.
+const maxSVDRegisters = 1 << 16
+
+type svdParser struct {
+	errs ErrorList
+	regs int
+	full bool
+}
+
+// room reserves n more registers, false once the device is full.
+func (p *svdParser) room(n int) bool {
+	if p.regs+n > maxSVDRegisters {
+		p.full = true
+		return false
+	}
+	p.regs += n
+	return true
+}
+
+// LoadSVD reads a CMSIS-SVD device description. Peripherals become banks;
+// peripheral, cluster, register and field arrays are expanded.
+// Problems with individual elements are returned as an ErrorList next to the
+// registers that could be read.
+func LoadSVD(r io.Reader) (*RegisterMap, error) {
+	var dev svdDevice
+	if err := xml.NewDecoder(r).Decode(&dev); err != nil {
+		return nil, fmt.Errorf("SVD解析失败: %v", err)
+	}
+	p := new(svdParser)
+	regMap := &RegisterMap{Name: dev.Name, Description: clean(dev.Description)}
+	defaults := p.defaults(dev.Name, svdDefaults{size: 32, reset: new(big.Int)}, dev.Size, dev.Access, dev.ResetValue)
+	peripherals := make(map[string]*svdPeripheral, len(dev.Peripherals))
+	for i := range dev.Peripherals {
+		peripherals[dev.Peripherals[i].Name] = &dev.Peripherals[i]
+	}
+	for i := range dev.Peripherals {
+		regMap.Banks = append(regMap.Banks, p.peripheral(&dev.Peripherals[i], peripherals, defaults)...)
+	}
+	if p.full {
+		p.errs.Add(dev.Name, "寄存器总数超过%d, 其余已忽略", maxSVDRegisters)
+	}
+	return validated(regMap, p.errs)
+}
+
+func (p *svdParser) unknown(path string, extra []svdAny, known map[string]bool) {
+	for _, e := range extra {
+		if !known[e.XMLName.Local] {
+			p.errs.Add(path, "未知元素 <%s>", e.XMLName.Local)
+		}
+	}
+}
+
+func (p *svdParser) defaults(path string, d svdDefaults, size, access, reset string) svdDefaults {
+	if size != "" {
+		if n, err := parseUint(size); err != nil || n == 0 || n > 4096 {
+			p.errs.Add(path, "无效size %q", size)
+		} else {
+			d.size = int(n)
+		}
+	}
+	if access != "" {
+		d.access = access
+	}
+	if reset != "" {
+		if n, err := ParseNumber(reset); err != nil {
+			p.errs.Add(path, "无效resetValue %q", reset)
+		} else {
+			d.reset = n
+		}
+	}
+	return d
+}
+
+// peripheral reads a peripheral, one bank per element of a peripheral array;
+// the elements share their registers.
+func (p *svdParser) peripheral(per *svdPeripheral, peripherals map[string]*svdPeripheral, d svdDefaults) []*Bank {
+	path := per.Name
+	if per.Name == "" {
+		p.errs.Add("<peripheral>", "缺少name")
+		return nil
+	}
+	p.unknown(path, per.Extra, svdPeripheralKnown)
+	names, step, ok := p.dim(path, per.Name, per.Dim, per.DimIncrement, per.DimIndex)
+	if !ok {
+		return nil
+	}
+	base, err := parseUint(per.BaseAddress)
+	if err != nil {
+		p.errs.Add(path, "无效baseAddress %q", per.BaseAddress)
+	}
+	bank := &Bank{Name: per.Name, Description: clean(per.Description), Base: base}
+	regs, inherited := per.Registers, false
+	if per.DerivedFrom != "" {
+		parent, ok := peripherals[per.DerivedFrom]
+		if !ok {
+			p.errs.Add(path, "derivedFrom %q 不存在", per.DerivedFrom)
+		} else {
+			d = p.defaults(path, d, parent.Size, parent.Access, parent.ResetValue)
+			if regs == nil {
+				regs, inherited = parent.Registers, true
+			}
+			if bank.Description == "" {
+				bank.Description = clean(parent.Description)
+			}
+		}
+	}
+	d = p.defaults(path, d, per.Size, per.Access, per.ResetValue)
+	if regs != nil {
+		// the parent already reported problems in the registers it shares
+		n := len(p.errs)
+		p.registers(bank, path, "", 0, regs.Registers, regs.Clusters, d)
+		p.unknown(path, regs.Extra, nil)
+		if inherited {
+			p.errs = p.errs[:n]
+		}
+	}
+	banks := make([]*Bank, 0, len(names))
+	for n, name := range names {
+		if n > 0 && !p.room(len(bank.Registers)) {
+			break
+		}
+		b := *bank
+		b.Name, b.Base = name, base+uint64(n)*step
+		banks = append(banks, &b)
+	}
+	return banks
+}
+
+func (p *svdParser) registers(bank *Bank, path, prefix string, offset uint64, regs []svdRegister, clusters []svdCluster, d svdDefaults) {
+	byName := make(map[string]*svdRegister, len(regs))
+	for i := range regs {
+		byName[regs[i].Name] = &regs[i]
+	}
+	for i := range regs {
+		reg := &regs[i]
+		if reg.DerivedFrom != "" {
+			parent, ok := byName[reg.DerivedFrom]
+			if !ok {
+				p.errs.Add(path+"."+reg.Name, "derivedFrom %q 不存在", reg.DerivedFrom)
+				continue
+			}
+			merged := *parent
+			merged.Name, merged.AddressOffset = reg.Name, reg.AddressOffset
+			if reg.Description != "" {
+				merged.Description = reg.Description
+			}
+			if len(reg.Fields) > 0 {
+				merged.Fields = reg.Fields
+			}
+			reg = &merged
+		}
+		p.register(bank, path, prefix, offset, reg, d)
+	}
+	for i := range clusters {
+		c := &clusters[i]
+		cpath := path + "." + c.Name
+		p.unknown(cpath, c.Extra, svdClusterKnown)
+		base, err := parseUint(c.AddressOffset)
+		if err != nil {
+			p.errs.Add(cpath, "无效addressOffset %q", c.AddressOffset)
+			continue
+		}
+		names, step, ok := p.dim(cpath, c.Name, c.Dim, c.DimIncrement, c.DimIndex)
+		if !ok {
+			continue
+		}
+		for n, name := range names {
+			if p.full {
+				return
+			}
+			p.registers(bank, cpath, prefix+name+"_", offset+base+uint64(n)*step, c.Registers, c.Clusters, d)
+		}
+	}
+}
+
+func (p *svdParser) register(bank *Bank, path, prefix string, offset uint64, reg *svdRegister, d svdDefaults) {
+	rpath := path + "." + reg.Name
+	if p.full {
+		return
+	}
+	if reg.Name == "" {
+		p.errs.Add(path+".<register>", "缺少name")
+		return
+	}
+	p.unknown(rpath, reg.Extra, svdRegisterKnown)
+	d = p.defaults(rpath, d, reg.Size, reg.Access, reg.ResetValue)
+	base, err := parseUint(reg.AddressOffset)
+	if err != nil {
+		p.errs.Add(rpath, "无效addressOffset %q", reg.AddressOffset)
+		return
+	}
+	var fields []Field
+	for i := range reg.Fields {
+		fields = append(fields, p.field(rpath, &reg.Fields[i], d)...)
+	}
+	names, step, ok := p.dim(rpath, reg.Name, reg.Dim, reg.DimIncrement, reg.DimIndex)
+	if !ok {
+		return
+	}
+	for n, name := range names {
+		if !p.room(1) {
+			return
+		}
+		layout := &Layout{
+			Name:        prefix + name,
+			Description: clean(reg.Description),
+			Width:       d.size,
+			Offset:      offset + base + uint64(n)*step,
+			Access:      d.access,
+			Reset:       new(big.Int).And(d.reset, Mask(d.size)),
+			Fields:      fields,
+		}
+		bank.Registers = append(bank.Registers, layout)
+	}
+}
+
+// field reads a field, one per element of a field array, each dimIncrement
+// bits above the last.
+func (p *svdParser) field(path string, f *svdField, d svdDefaults) []Field {
+	fpath := path + "." + f.Name
+	if f.Name == "" {
+		p.errs.Add(path+".<field>", "缺少name")
+		return nil
+	}
+	p.unknown(fpath, f.Extra, svdFieldKnown)
+	var msb, lsb uint64
+	var err error
+	switch {
+	case f.BitRange != "":
+		match := svdBitRange.FindStringSubmatch(strings.TrimSpace(f.BitRange))
+		if match == nil {
+			p.errs.Add(fpath, "无效bitRange %q", f.BitRange)
+			return nil
+		}
+		msb, _ = strconv.ParseUint(match[1], 10, 32)
+		lsb, _ = strconv.ParseUint(match[2], 10, 32)
+	case f.Lsb != "" || f.Msb != "":
+		lsb, err = parseUint(f.Lsb)
+		if err == nil {
+			msb, err = parseUint(f.Msb)
+		}
+		if err != nil {
+			p.errs.Add(fpath, "无效lsb/msb")
+			return nil
+		}
+	case f.BitOffset != "":
+		lsb, err = parseUint(f.BitOffset)
+		width := uint64(1)
+		if err == nil && f.BitWidth != "" {
+			width, err = parseUint(f.BitWidth)
+		}
+		// lsb+width could wrap, so check against the size first
+		if err != nil || width == 0 || lsb >= uint64(d.size) || width > uint64(d.size)-lsb {
+			p.errs.Add(fpath, "无效bitOffset/bitWidth")
+			return nil
+		}
+		msb = lsb + width - 1
+	default:
+		p.errs.Add(fpath, "缺少位位置")
+		return nil
+	}
+	if msb < lsb {
+		msb, lsb = lsb, msb
+	}
+	if msb >= uint64(d.size) {
+		p.errs.Add(fpath, "位范围[%d:%d]超出寄存器宽度%d", msb, lsb, d.size)
+		return nil
+	}
+	names, step, ok := p.dim(fpath, f.Name, f.Dim, f.DimIncrement, f.DimIndex)
+	if !ok {
+		return nil
+	}
+	// step is bounded first so the product below cannot wrap
+	if last := uint64(len(names) - 1); last > 0 && (step == 0 || step > uint64(d.size) || msb+last*step >= uint64(d.size)) {
+		p.errs.Add(fpath, "位域数组超出寄存器宽度%d", d.size)
+		return nil
+	}
+	access := f.Access
+	if access == "" {
+		access = d.access
+	}
+	field := Field{
+		Name:        f.Name,
+		Msb:         int(msb),
+		Lsb:         int(lsb),
+		Description: clean(f.Description),
+		Access:      access,
+		WriteAction: f.WriteAction,
+		ReadAction:  f.ReadAction,
+	}
+	for _, enums := range f.Enums {
+		for _, v := range enums.Values {
+			// isDefault entries carry no value
+			if v.Value != "" {
+				addEnum(&p.errs, fpath, &field, v.Name, v.Value, v.Description)
+			}
+		}
+	}
+	fields := make([]Field, len(names))
+	for n, name := range names {
+		fields[n] = field
+		fields[n].Name = name
+		fields[n].Msb += n * int(step)
+		fields[n].Lsb += n * int(step)
+	}
+	return fields
+}
+
+// dim expands SVD arrays. Names use %s for the index; "[%s]" keeps the
+// C array notation.
+func (p *svdParser) dim(path, name, dim, increment, index string) ([]string, uint64, bool) {
+	if dim == "" {
+		if strings.Contains(name, "%s") {
+			p.errs.Add(path, "名称含%%s但缺少dim")
+			return nil, 0, false
+		}
+		return []string{name}, 0, true
+	}
+	n, err := parseUint(dim)
+	if err != nil || n == 0 || n > 4096 {
+		p.errs.Add(path, "无效dim %q", dim)
+		return nil, 0, false
+	}
+	step, err := parseUint(increment)
+	if err != nil {
+		p.errs.Add(path, "无效dimIncrement %q", increment)
+		return nil, 0, false
+	}
+	indexes, err := dimIndexes(int(n), index)
+	if err != nil {
+		p.errs.Add(path, "%v", err)
+		return nil, 0, false
+	}
+	names := make([]string, len(indexes))
+	for i, ix := range indexes {
+		if strings.Contains(name, "[%s]") {
+			names[i] = strings.Replace(name, "[%s]", "["+ix+"]", 1)
+		} else {
+			names[i] = strings.Replace(name, "%s", ix, 1)
+		}
+	}
+	return names, step, true
+}
+
+func dimIndexes(n int, index string) ([]string, error) {
+	index = strings.TrimSpace(index)
+	indexes := make([]string, 0, n)
+	switch {
+	case index == "":
+		for i := 0; i < n; i++ {
+			indexes = append(indexes, fmt.Sprint(i))
+		}
+	case strings.Contains(index, ","):
+		for _, ix := range strings.Split(index, ",") {
+			indexes = append(indexes, strings.TrimSpace(ix))
+		}
+	case strings.Contains(index, "-"):
+		ends := strings.SplitN(index, "-", 2)
+		from, errF := strconv.Atoi(ends[0])
+		to, errT := strconv.Atoi(ends[1])
+		if errF == nil && errT == nil {
+			// checked before the loop, dimIndex comes straight from the file
+			if from < 0 || to-from+1 != n {
+				break
+			}
+			for i := from; i <= to; i++ {
+				indexes = append(indexes, fmt.Sprint(i))
+			}
+		} else if len(ends[0]) == 1 && len(ends[1]) == 1 {
+			for c := int(ends[0][0]); c <= int(ends[1][0]); c++ {
+				indexes = append(indexes, string(rune(c)))
+			}
+		}
+	default:
+		indexes = append(indexes, index)
+	}
+	if len(indexes) != n {
+		return nil, fmt.Errorf("dimIndex %q 与dim %d 不符", index, n)
+	}
+	return indexes, nil
+}
+
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
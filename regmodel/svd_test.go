@@ -0,0 +1,164 @@
+package regmodel
+
+import (
+	"strings"
+	"testing"
+)
+
+const svdSample = `<?xml version="1.0" encoding="utf-8"?>
+<device>
+  <name>DEMO</name>
+  <size>32</size>
+  <resetValue>0</resetValue>
+  <peripherals>
+    <peripheral>
+      <name>TIM1</name>
+      <baseAddress>0x40010000</baseAddress>
+      <registers>
+        <register>
+          <name>CR1</name>
+          <description>control
+            register 1</description>
+          <addressOffset>0x0</addressOffset>
+          <resetValue>0x00000001</resetValue>
+          <fields>
+            <field>
+              <name>CEN</name>
+              <bitOffset>0</bitOffset>
+              <bitWidth>1</bitWidth>
+              <access>read-write</access>
+            </field>
+            <field>
+              <name>CKD</name>
+              <bitRange>[9:8]</bitRange>
+              <enumeratedValues>
+                <enumeratedValue><name>Div1</name><value>0</value></enumeratedValue>
+                <enumeratedValue><name>Div2</name><value>#01</value></enumeratedValue>
+                <enumeratedValue><name>Other</name><isDefault>true</isDefault></enumeratedValue>
+              </enumeratedValues>
+            </field>
+          </fields>
+        </register>
+        <register>
+          <name>CCR%s</name>
+          <addressOffset>0x34</addressOffset>
+          <dim>4</dim>
+          <dimIncrement>4</dimIncrement>
+          <dimIndex>1-4</dimIndex>
+          <size>16</size>
+        </register>
+      </registers>
+    </peripheral>
+    <peripheral derivedFrom="TIM1">
+      <name>TIM2</name>
+      <baseAddress>0x40000000</baseAddress>
+    </peripheral>
+    <peripheral>
+      <name>GPIO%s</name>
+      <baseAddress>0x48000000</baseAddress>
+      <dim>2</dim>
+      <dimIncrement>0x400</dimIncrement>
+      <dimIndex>A,B</dimIndex>
+      <registers>
+        <register>
+          <name>MODER</name>
+          <addressOffset>0</addressOffset>
+          <fields>
+            <field>
+              <name>MODE%s</name>
+              <bitOffset>4</bitOffset>
+              <bitWidth>2</bitWidth>
+              <dim>3</dim>
+              <dimIncrement>2</dimIncrement>
+            </field>
+          </fields>
+        </register>
+      </registers>
+    </peripheral>
+  </peripherals>
+</device>`
+
+func TestLoadSVD(t *testing.T) {
+	m, err := LoadSVD(strings.NewReader(svdSample))
+	if msg := loaded(t, m, err); msg != "" {
+		t.Fatalf("errors: %s", msg)
+	}
+	if m.Name != "DEMO" || len(m.Banks) != 4 || m.Banks[0].Base != 0x40010000 {
+		t.Fatalf("map %s with %d banks", m.Name, len(m.Banks))
+	}
+	cr1 := layout(t, m, "TIM1", "CR1")
+	if cr1.Description != "control register 1" || cr1.Reset.Int64() != 1 || len(cr1.Fields) != 2 {
+		t.Errorf("CR1 = %+v", cr1)
+	}
+	ckd := cr1.Field("CKD")
+	if ckd == nil || ckd.Msb != 9 || ckd.Lsb != 8 {
+		t.Errorf("CKD = %+v", ckd)
+	}
+	if len(ckd.Enums) != 2 || ckd.Enums[1].Value.Int64() != 1 {
+		t.Errorf("CKD enums = %+v", ckd.Enums)
+	}
+	if cen := cr1.Field("CEN"); cen == nil || cen.Access != "read-write" {
+		t.Errorf("CEN = %+v", cen)
+	}
+	for i, name := range []string{"CCR1", "CCR2", "CCR3", "CCR4"} {
+		if l := layout(t, m, "TIM1", name); l.Offset != uint64(0x34+4*i) || l.Width != 16 {
+			t.Errorf("%s at 0x%x width %d", name, l.Offset, l.Width)
+		}
+	}
+	if tim2 := m.Bank("TIM2"); tim2 == nil || len(tim2.Registers) != 5 {
+		t.Errorf("TIM2 does not derive the registers of TIM1")
+	}
+	if gpiob := m.Bank("GPIOB"); gpiob == nil || gpiob.Base != 0x48000400 || len(gpiob.Registers) != 1 {
+		t.Fatalf("GPIOB = %+v", gpiob)
+	}
+	moder := layout(t, m, "GPIOB", "MODER")
+	for i, name := range []string{"MODE0", "MODE1", "MODE2"} {
+		if f := moder.Field(name); f == nil || f.Lsb != 4+2*i || f.Msb != 5+2*i {
+			t.Errorf("%s = %+v", name, f)
+		}
+	}
+}
+
+func TestLoadSVDLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		reg  string
+		want string
+	}{
+		{"dim", `<name>R%s</name><addressOffset>0</addressOffset><dim>0x7fffffff</dim><dimIncrement>4</dimIncrement>`, "无效dim"},
+		{"size", `<name>R</name><addressOffset>0</addressOffset><size>0x10000000</size>`, "无效size"},
+		{"bitOffset", `<name>R</name><addressOffset>0</addressOffset>
+			<fields><field><name>F</name><bitOffset>0xffffffffffffffff</bitOffset><bitWidth>2</bitWidth></field></fields>`, "无效bitOffset/bitWidth"},
+		{"bitWidth", `<name>R</name><addressOffset>0</addressOffset>
+			<fields><field><name>F</name><bitOffset>4</bitOffset><bitWidth>0xffffffffffffffff</bitWidth></field></fields>`, "无效bitOffset/bitWidth"},
+		{"bitRange", `<name>R</name><addressOffset>0</addressOffset>
+			<fields><field><name>F</name><bitRange>[40:0]</bitRange></field></fields>`, "超出寄存器宽度"},
+		{"field dim", `<name>R</name><addressOffset>0</addressOffset>
+			<fields><field><name>F%s</name><bitOffset>24</bitOffset><bitWidth>4</bitWidth><dim>3</dim><dimIncrement>4</dimIncrement></field></fields>`, "位域数组超出寄存器宽度"},
+		{"field dim step", `<name>R</name><addressOffset>0</addressOffset>
+			<fields><field><name>F%s</name><bitOffset>0</bitOffset><dim>2</dim><dimIncrement>0xffffffffffffffff</dimIncrement></field></fields>`, "位域数组超出寄存器宽度"},
+	}
+	for _, tt := range tests {
+		src := `<device><name>D</name><peripherals><peripheral><name>P</name><baseAddress>0</baseAddress>
+			<registers><register>` + tt.reg + `</register></registers></peripheral></peripherals></device>`
+		m, err := LoadSVD(strings.NewReader(src))
+		if msg := loaded(t, m, err); !strings.Contains(msg, tt.want) {
+			t.Errorf("%s: errors %q, want %q", tt.name, msg, tt.want)
+		}
+	}
+}
+
+func TestLoadSVDTotal(t *testing.T) {
+	// 4096 clusters of 4096 registers each, over the device cap
+	src := `<device><name>D</name><peripherals><peripheral><name>P</name><baseAddress>0</baseAddress>
+		<registers><cluster><name>C%s</name><addressOffset>0</addressOffset><dim>4096</dim><dimIncrement>0x10000</dimIncrement>
+		<register><name>R%s</name><addressOffset>0</addressOffset><dim>4096</dim><dimIncrement>4</dimIncrement></register>
+		</cluster></registers></peripheral></peripherals></device>`
+	m, err := LoadSVD(strings.NewReader(src))
+	if msg := loaded(t, m, err); !strings.Contains(msg, "寄存器总数超过65536") {
+		t.Errorf("errors %q", msg)
+	}
+	if n := len(m.Bank("P").Registers); n != maxSVDRegisters {
+		t.Errorf("%d registers loaded", n)
+	}
+}
@@ -0,0 +1,75 @@
+package regmodel
+
+import (
+	"math/big"
+	"time"
+)
+
+// ValueRecord is a value a row held, when it got it and the edit that set it.
+type ValueRecord struct {
+	Time   time.Time
+	Value  *big.Int
+	Action string
+}
+
+// Timeline logs the values of one row, leaving out repeats of the last one.
+type Timeline struct {
+	records []ValueRecord
+	Limit   int
+}
+
+// Add logs v unless it equals the last value. With merge set, a record whose
+// action equals the last one replaces it, so typing a number logs only the
+// final value.
+func (t *Timeline) Add(v *big.Int, action string, merge bool) {
+	n := len(t.records)
+	if n > 0 && t.records[n-1].Value.Cmp(v) == 0 {
+		return
+	}
+	record := ValueRecord{time.Now(), new(big.Int).Set(v), action}
+	if merge && n > 0 && t.records[n-1].Action == action {
+		t.records[n-1] = record
+		if n > 1 && t.records[n-2].Value.Cmp(v) == 0 {
+			t.records = t.records[:n-1]
+		}
+		return
+	}
+	t.records = append(t.records, record)
+	if t.Limit > 0 && len(t.records) > t.Limit {
+		t.records = t.records[len(t.records)-t.Limit:]
+	}
+}
+
+func (t *Timeline) Records() []ValueRecord {
+	return t.records
+}
+
+// FieldChange is a field whose value differs between two register values.
+type FieldChange struct {
+	Field    *Field
+	From, To *big.Int
+}
+
+// Changes lists the fields of l that differ between from and to, and the
+// changed bits that are in no field. l may be nil.
+func Changes(l *Layout, from, to *big.Int) ([]FieldChange, *big.Int) {
+	diff := new(big.Int).Xor(from, to)
+	var changes []FieldChange
+	if l != nil {
+		for i := range l.Fields {
+			f := &l.Fields[i]
+			mask := f.Mask()
+			if new(big.Int).And(diff, mask).Sign() == 0 {
+				continue
+			}
+			changes = append(changes, FieldChange{f, fieldBits(from, f), fieldBits(to, f)})
+			diff.AndNot(diff, mask)
+		}
+	}
+	return changes, diff
+}
+
+func fieldBits(v *big.Int, f *Field) *big.Int {
+	bits := new(big.Int).Rsh(v, uint(f.Lsb))
+	return bits.And(bits, Mask(f.Width()))
+}